@@ -0,0 +1,101 @@
+package uploadbig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Append uploads the bytes written to the file since the previous Append.
+// The last sent offset and the session ID are kept in the state file set by
+// SetStatePath, so repeated calls ship successive appends to the same
+// remote object. The state is saved after every committed part, so an
+// interrupted Append continues from the last part. With CRC32C, a tree
+// hash or a verify by download, the bytes sent before are read again, so
+// the checksums cover the whole remote object.
+func (c *UploadData) Append() (err error) {
+	if c.statePath == "" {
+		return errors.New("state path is not set")
+	}
+	if c.fromReader {
+		return errors.New("append needs a file source")
+	}
+	if err := c.validateSource(); err != nil {
+		return err
+	}
+
+	c.startRun()
+	defer func() {
+		c.endRun(err)
+	}()
+
+	state, err := LoadState(c.statePath)
+	if os.IsNotExist(err) {
+		state = ResumeState{SessionID: c.id, FilePath: c.filePath}
+	} else if c.checkError(err) {
+		return err
+	}
+	c.setSessionID(state.SessionID)
+
+	fileStat, err := os.Stat(c.filePath)
+	if c.checkError(err) {
+		return err
+	}
+
+	size := fileStat.Size()
+	if size < state.Offset {
+		err = fmt.Errorf("file %s is %d bytes, shorter than already sent %d bytes", c.filePath, size, state.Offset)
+		c.checkError(err)
+		return err
+	}
+	if size == state.Offset {
+		c.infof("Nothing to append")
+		return nil
+	}
+
+	c.failure = nil
+	c.chunks = coalescePlan(buildRangePlan(state.Offset, size, c.chunkSize), c.minPartSize, size)
+	c.statusMutex.Lock()
+	c.Status = UploadStatus{Size: size - state.Offset, Parts: uint64(len(c.chunks))}
+	c.statusMutex.Unlock()
+	c.startCommits()
+	c.startVerify()
+	err = c.startTreeHash()
+	if c.checkError(err) {
+		return err
+	}
+
+	err = c.openFile()
+	if c.checkError(err) {
+		return err
+	}
+	err = c.rehash(0, state.Offset)
+	if c.checkError(err) {
+		return err
+	}
+
+	state.Size = size
+	state.ModTime = fileStat.ModTime()
+	c.partCommitted = func(chunk ChunkSpec) {
+		state.Offset = chunk.Offset + int64(chunk.Length)
+		c.checkError(SaveState(c.statePath, state))
+	}
+	defer func() {
+		c.partCommitted = nil
+	}()
+
+	c.uploadFile(0)
+	c.Close()
+
+	if c.Status.TransferredException {
+		if c.failure != nil {
+			return fmt.Errorf("append stopped at offset %d: %w", state.Offset, c.failure)
+		}
+		if c.ctx.Err() != nil {
+			return fmt.Errorf("append stopped at offset %d: %w", state.Offset, c.runError())
+		}
+		return fmt.Errorf("append stopped at offset %d", state.Offset)
+	}
+	c.infof("Appended up to offset %d", state.Offset)
+	return nil
+}
@@ -0,0 +1,77 @@
+package uploadbig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAppendSendsOnlyNewBytes(t *testing.T) {
+	server := newTestServer(t, nil)
+	path := testFile(t, 1500)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	u := New("PUT", server.URL, path, server.Client(), 1000, nil)
+	u.SetStatePath(statePath)
+	if err := u.Append(); err != nil {
+		t.Fatal(err)
+	}
+
+	content := testContent(2200)
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	u = New("PUT", server.URL, path, server.Client(), 1000, nil)
+	u.SetStatePath(statePath)
+	if err := u.Append(); err != nil {
+		t.Fatal(err)
+	}
+	// nothing was appended since
+	if err := u.Append(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"bytes 0-999/1500", "bytes 1000-1499/1500", "bytes 1500-2199/2200"}
+	if got := server.Headers("Content-Range"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+	requests := server.Requests()
+	if !bytes.Equal(requests[2].Body, content[1500:]) {
+		t.Fatal("appended bytes differ")
+	}
+	if ids := server.Headers("Session-ID"); ids[0] != ids[2] {
+		t.Fatalf("session changed from %s to %s", ids[0], ids[2])
+	}
+}
+
+func TestAppendChecksumsCoverTheObject(t *testing.T) {
+	content := testContent(2*MB + 100)
+	server := storeServer(t, len(content), -1)
+	path := testFile(t, MB)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	u := New("PUT", server.URL+"/upload", path, server.Client(), MB, nil)
+	u.SetStatePath(statePath)
+	if err := u.Append(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	u = New("PUT", server.URL+"/upload", path, server.Client(), MB, nil)
+	u.SetStatePath(statePath)
+	u.SetVerifyByDownload(server.URL + "/object")
+	u.SetTreeHash(true)
+	if err := u.Append(); err != nil {
+		t.Fatal(err)
+	}
+	// the first megabyte, sent by the previous Append, is hashed too
+	l0, l1, l2 := sha256Of(content[:MB]), sha256Of(content[MB:2*MB]), sha256Of(content[2*MB:])
+	if got, want := u.TreeHash(), hex.EncodeToString(sha256Of(sha256Of(l0, l1), l2)); got != want {
+		t.Fatalf("tree hash %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package uploadbig
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchUploader runs several uploads at once under a shared request
+// Limiter and BandwidthLimiter and reports their progress combined
+type BatchUploader struct {
+	limiter   *Limiter
+	bandwidth *BandwidthLimiter
+	uploads   []*UploadData
+	samplers  []speedSampler
+
+	progressHandler ProgressFunc
+	progressMutex   sync.Mutex
+}
+
+// NewBatchUploader creates a batch sharing limiter and bandwidth, either
+// of which may be nil for no cap
+func NewBatchUploader(limiter *Limiter, bandwidth *BandwidthLimiter) *BatchUploader {
+	return &BatchUploader{limiter: limiter, bandwidth: bandwidth}
+}
+
+// Add adds upload to the batch and puts it under the shared limiters
+func (b *BatchUploader) Add(upload *UploadData) {
+	if b.limiter != nil {
+		upload.SetLimiter(b.limiter)
+	}
+	if b.bandwidth != nil {
+		upload.SetBandwidthLimiter(b.bandwidth)
+	}
+	b.uploads = append(b.uploads, upload)
+}
+
+// SetProgressHandler makes handler be called with the combined Status
+// after a chunk of any upload has been transferred, one call at a time.
+// The handlers set on the uploads are still called.
+func (b *BatchUploader) SetProgressHandler(handler ProgressFunc) {
+	b.progressHandler = handler
+}
+
+// SpeedSamples returns a channel receiving the combined rate of the uploads
+// in bytes per second over the last interval, every interval. It must be
+// called before Run; the channel is closed when Run returns.
+func (b *BatchUploader) SpeedSamples(interval time.Duration) <-chan float64 {
+	samples := make(chan float64, 1)
+	b.samplers = append(b.samplers, speedSampler{interval: interval, samples: samples})
+	return samples
+}
+
+// Status returns the sum of the uploads' statuses. It is done when every
+// upload is done and failed when any has failed. An upload that hasn't
+// started yet counts with size 0.
+func (b *BatchUploader) Status() UploadStatus {
+	combined := UploadStatus{IsDone: true}
+	for _, upload := range b.uploads {
+		status := upload.Snapshot()
+		combined.Size += status.Size
+		combined.SizeTransferred += status.SizeTransferred
+		combined.Parts += status.Parts
+		combined.PartsTransferred += status.PartsTransferred
+		combined.IsDone = combined.IsDone && status.IsDone
+		combined.TransferredException = combined.TransferredException || status.TransferredException
+	}
+	return combined
+}
+
+// Run runs all the uploads at once with Init and waits for them. It returns
+// the error of the first failed upload in the order they were added.
+func (b *BatchUploader) Run() error {
+	for _, upload := range b.uploads {
+		b.chainProgress(upload)
+	}
+
+	stop := make(chan struct{})
+	var samplersWait sync.WaitGroup
+	for _, sampler := range b.samplers {
+		samplersWait.Add(1)
+		go runSampler(sampler, b.sampledTransferred, stop, &samplersWait)
+	}
+	b.samplers = nil
+
+	errs := make([]error, len(b.uploads))
+	var wg sync.WaitGroup
+	for n, upload := range b.uploads {
+		wg.Add(1)
+		go func(n int, upload *UploadData) {
+			defer wg.Done()
+			errs[n] = upload.Init()
+		}(n, upload)
+	}
+	wg.Wait()
+	close(stop)
+	samplersWait.Wait()
+
+	for n, err := range errs {
+		if err != nil {
+			return fmt.Errorf("upload %d of the batch: %w", n, err)
+		}
+	}
+	return nil
+}
+
+// chainProgress makes the progress of upload call the batch handler too
+func (b *BatchUploader) chainProgress(upload *UploadData) {
+	if b.progressHandler == nil {
+		return
+	}
+	own := upload.progressHandler
+	upload.progressHandler = func(status UploadStatus) {
+		if own != nil {
+			own(status)
+		}
+		b.progressMutex.Lock()
+		defer b.progressMutex.Unlock()
+		b.progressHandler(b.Status())
+	}
+}
+
+func (b *BatchUploader) sampledTransferred() int64 {
+	var size int64
+	for _, upload := range b.uploads {
+		size += upload.sampledTransferred()
+	}
+	return size
+}
@@ -0,0 +1,69 @@
+package uploadbig
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatchSharedLimits(t *testing.T) {
+	var running, maxRunning int32
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if now <= max || atomic.CompareAndSwapInt32(&maxRunning, max, now) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	})
+	batch := NewBatchUploader(NewLimiter(2), NewBandwidthLimiter(10000))
+	for i := 0; i < 3; i++ {
+		u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+		u.SetConcurrency(3)
+		batch.Add(u)
+	}
+	var last UploadStatus
+	calls := 0
+	batch.SetProgressHandler(func(status UploadStatus) {
+		last = status
+		calls++
+	})
+	samples := batch.SpeedSamples(100 * time.Millisecond)
+	sampled := make(chan int)
+	go func() {
+		moving := 0
+		for sample := range samples {
+			if sample > 0 {
+				moving++
+			}
+		}
+		sampled <- moving
+	}()
+
+	start := time.Now()
+	if err := batch.Run(); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(start)
+
+	if max := atomic.LoadInt32(&maxRunning); max > 2 {
+		t.Errorf("%d requests at once, the limiter allows 2", max)
+	}
+	// the first chunk goes at once, the other 8000 bytes take 800ms
+	if elapsed < 700*time.Millisecond {
+		t.Errorf("uploaded 9000 bytes in %v at 10000 bytes per second", elapsed)
+	}
+	if calls != 9 || last.PartsTransferred != 9 || last.SizeTransferred != 9000 {
+		t.Errorf("%d progress calls, last %+v", calls, last)
+	}
+	if moving := <-sampled; moving == 0 {
+		t.Error("no throughput sample above zero")
+	}
+	if status := batch.Status(); !status.IsDone || status.Size != 9000 {
+		t.Errorf("batch status %+v", status)
+	}
+}
@@ -0,0 +1,65 @@
+package uploadbig
+
+// CompletedBitmap records the completed parts of an upload with one bit
+// per part, compact enough to persist for millions of parts
+type CompletedBitmap []byte
+
+// Set marks part index as completed
+func (b *CompletedBitmap) Set(index uint64) {
+	for uint64(len(*b)) <= index/8 {
+		*b = append(*b, 0)
+	}
+	(*b)[index/8] |= 1 << (index % 8)
+}
+
+// Test reports whether part index is completed
+func (b CompletedBitmap) Test(index uint64) bool {
+	return index/8 < uint64(len(b)) && b[index/8]&(1<<(index%8)) != 0
+}
+
+// MarshalBinary returns the bits, the lowest bit of the first byte is part 0
+func (b CompletedBitmap) MarshalBinary() ([]byte, error) {
+	return append([]byte(nil), b...), nil
+}
+
+// UnmarshalBinary replaces the bits with data from MarshalBinary
+func (b *CompletedBitmap) UnmarshalBinary(data []byte) error {
+	*b = append(CompletedBitmap(nil), data...)
+	return nil
+}
+
+// SetCompletedBitmap makes the upload skip the parts set in bitmap and set
+// the bits of the parts it completes, so the bitmap can be persisted to
+// resume later. Skipped parts of a reader, or of an upload with CRC32C or
+// a tree hash, are still read to keep the position and the checksums.
+func (c *UploadData) SetCompletedBitmap(bitmap *CompletedBitmap) {
+	c.completed = bitmap
+}
+
+// skipCompleted accounts chunk as transferred without sending it
+func (c *UploadData) skipCompleted(chunk ChunkSpec) error {
+	if c.fromReader || c.wrapped != nil || c.rehashing() {
+		part := make([]byte, chunk.Length)
+		_, err := c.readChunk(chunk, part)
+		if err != nil {
+			return err
+		}
+		c.hashTreeLeaves(chunk.Offset, part)
+		c.updateCRC32C(part)
+		c.hashVerified(chunk.Offset, part)
+	}
+
+	c.debugf("Part %d is already completed", chunk.Index)
+	if c.commits != nil {
+		err := c.commits.skip(chunk.Index)
+		if err != nil {
+			return err
+		}
+	}
+	c.setSizeTransferred(c.Status.SizeTransferred + int64(chunk.Length))
+	c.statusMutex.Lock()
+	c.Status.PartsTransferred++
+	c.statusMutex.Unlock()
+	c.emitProgress()
+	return nil
+}
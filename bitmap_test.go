@@ -0,0 +1,73 @@
+package uploadbig
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCompletedBitmapSkipsParts(t *testing.T) {
+	server := newTestServer(t, nil)
+	var saved CompletedBitmap
+	saved.Set(0)
+	saved.Set(2)
+	saved.Set(9)
+	data, err := saved.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bitmap CompletedBitmap
+	if err := bitmap.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	u := New("PUT", server.URL, testFile(t, 11000), server.Client(), 1000, nil)
+	u.SetCompletedBitmap(&bitmap)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ranges []string
+	for _, request := range server.Requests() {
+		ranges = append(ranges, request.Header.Get("Content-Range"))
+	}
+	want := []string{
+		"bytes 1000-1999/11000", "bytes 3000-3999/11000", "bytes 4000-4999/11000",
+		"bytes 5000-5999/11000", "bytes 6000-6999/11000", "bytes 7000-7999/11000",
+		"bytes 8000-8999/11000", "bytes 10000-10999/11000",
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges %q, want %q", ranges, want)
+	}
+	if u.Status.SizeTransferred != 11000 {
+		t.Fatalf("transferred %d bytes", u.Status.SizeTransferred)
+	}
+	for i := uint64(0); i < 11; i++ {
+		if !bitmap.Test(i) {
+			t.Fatalf("part %d is not set", i)
+		}
+	}
+}
+
+func TestCompletedBitmapStrictCommits(t *testing.T) {
+	server := newTestServer(t, nil)
+	var bitmap CompletedBitmap
+	bitmap.Set(1)
+	u := New("PUT", server.URL+"/data", testFile(t, 3000), server.Client(), 1000, nil)
+	u.SetCompletedBitmap(&bitmap)
+	u.SetCommit(FlushRequest{Method: "POST", URL: server.URL + "/commit"}, CommitOrderStrict)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the skipped part is not committed again, nor holds back the next one
+	var offsets []string
+	for _, request := range server.Requests() {
+		if request.Method == http.MethodPost {
+			offsets = append(offsets, request.Header.Get("Upload-Offset"))
+		}
+	}
+	if want := []string{"1000", "3000"}; !reflect.DeepEqual(offsets, want) {
+		t.Fatalf("committed offsets %q, want %q", offsets, want)
+	}
+}
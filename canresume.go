@@ -0,0 +1,80 @@
+package uploadbig
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+)
+
+// SetSessionProbe makes CanResume ask the server whether it still has the
+// session. The request is sent with the Session-ID header; 404 and 410
+// mean the session is gone. An empty method defaults to HEAD.
+func (c *UploadData) SetSessionProbe(method string, url string) {
+	if method == "" {
+		method = http.MethodHead
+	}
+	c.probeMethod = method
+	c.probeURL = url
+}
+
+// CanResume reports whether the state Init, Resume or Append saved in the
+// state file is still usable: the local file has the size and modification time it had when
+// the state was saved and, if a session probe is set, the server still
+// knows the session. reason explains a negative answer.
+func (c *UploadData) CanResume() (bool, string, error) {
+	if c.statePath == "" {
+		return false, "state path is not set", nil
+	}
+
+	state, err := LoadState(c.statePath)
+	if os.IsNotExist(err) {
+		return false, "no saved state", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	fileStat, err := os.Stat(c.filePath)
+	if os.IsNotExist(err) {
+		return false, "local file is missing", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	if fileStat.Size() != state.Size || !fileStat.ModTime().Equal(state.ModTime) {
+		return false, "local file has changed", nil
+	}
+
+	if c.probeURL == "" {
+		return true, "", nil
+	}
+	return c.probeSession(state.SessionID)
+}
+
+func (c *UploadData) probeSession(sessionID string) (bool, string, error) {
+	request, err := http.NewRequestWithContext(c.ctx, c.probeMethod, c.probeURL, nil)
+	if err != nil {
+		return false, "", err
+	}
+	request.Header.Set("Session-ID", sessionID)
+	setHeaders(request, c.sharedHeaders(nil))
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return false, "", err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	c.debugf("Session probe HTTP code %d", response.StatusCode)
+	switch {
+	case response.StatusCode == http.StatusNotFound || response.StatusCode == http.StatusGone:
+		return false, "server session is gone", nil
+	case response.StatusCode >= 200 && response.StatusCode <= 299:
+		return true, "", nil
+	default:
+		return false, "", fmt.Errorf("session probe failed with HTTP code %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,72 @@
+package uploadbig
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCanResume(t *testing.T) {
+	var gone int32
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodHead && atomic.LoadInt32(&gone) == 1 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	path := testFile(t, 1500)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	u := New("PUT", server.URL, path, server.Client(), 1000, nil)
+	u.SetStatePath(statePath)
+	u.SetSessionProbe("", server.URL)
+	if ok, reason, err := u.CanResume(); ok || reason != "no saved state" || err != nil {
+		t.Fatalf("before Init: %v %q %v", ok, reason, err)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason, err := u.CanResume(); !ok || err != nil {
+		t.Fatalf("after Init: %q %v", reason, err)
+	}
+
+	atomic.StoreInt32(&gone, 1)
+	if ok, reason, err := u.CanResume(); ok || reason != "server session is gone" || err != nil {
+		t.Fatalf("session gone: %v %q %v", ok, reason, err)
+	}
+
+	atomic.StoreInt32(&gone, 0)
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason, err := u.CanResume(); ok || reason != "local file has changed" || err != nil {
+		t.Fatalf("file changed: %v %q %v", ok, reason, err)
+	}
+}
+
+func TestResumeContinuesSavedSession(t *testing.T) {
+	server := newTestServer(t, nil)
+	path := testFile(t, 1500)
+	statePath := filepath.Join(t.TempDir(), "state.json")
+
+	u := New("PUT", server.URL, path, server.Client(), 1000, nil)
+	u.SetStatePath(statePath)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	u = New("PUT", server.URL, path, server.Client(), 1000, nil)
+	u.SetStatePath(statePath)
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	ids := server.Headers("Session-ID")
+	for _, id := range ids[1:] {
+		if id != ids[0] {
+			t.Fatalf("session IDs %q, want one session", ids)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package uploadbig
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Capabilities is what the server advertises about the uploads it accepts
+type Capabilities struct {
+	// Ranges is true for "Accept-Ranges: bytes"
+	Ranges bool
+	// Resumable is true when the server speaks tus (Tus-Resumable)
+	Resumable  bool
+	TusVersion string
+	Extensions []string
+	// MaxSize is the largest accepted upload (Tus-Max-Size), 0 if unknown
+	MaxSize int64
+}
+
+// SetCapabilityProbe sets the request sent by ProbeCapabilities. By default
+// it is OPTIONS to the upload URL.
+func (c *UploadData) SetCapabilityProbe(method string, url string) {
+	c.capabilityMethod = method
+	c.capabilityURL = url
+}
+
+// ProbeCapabilities asks the server which upload features it supports, so
+// the caller can decide e.g. between a resumable and a single-shot upload
+func (c *UploadData) ProbeCapabilities() (Capabilities, error) {
+	method, url := c.capabilityMethod, c.capabilityURL
+	if method == "" {
+		method = http.MethodOptions
+	}
+	if url == "" {
+		url = c.url
+	}
+
+	var capabilities Capabilities
+	request, err := http.NewRequestWithContext(c.ctx, method, url, nil)
+	if err != nil {
+		return capabilities, err
+	}
+	request.Header.Set("Tus-Resumable", TusVersion)
+	setHeaders(request, c.sharedHeaders(nil))
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return capabilities, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	c.debugf("Capability probe HTTP code %d", response.StatusCode)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return capabilities, fmt.Errorf("capability probe failed with HTTP code %d", response.StatusCode)
+	}
+
+	header := response.Header
+	capabilities.Ranges = strings.EqualFold(header.Get("Accept-Ranges"), "bytes")
+	capabilities.TusVersion = header.Get("Tus-Resumable")
+	capabilities.Resumable = capabilities.TusVersion != ""
+	if extensions := header.Get("Tus-Extension"); extensions != "" {
+		for _, extension := range strings.Split(extensions, ",") {
+			capabilities.Extensions = append(capabilities.Extensions, strings.TrimSpace(extension))
+		}
+	}
+	if maxSize := header.Get("Tus-Max-Size"); maxSize != "" {
+		capabilities.MaxSize, err = strconv.ParseInt(maxSize, 10, 64)
+		if err != nil {
+			return capabilities, fmt.Errorf("can't parse Tus-Max-Size %q: %v", maxSize, err)
+		}
+	}
+	return capabilities, nil
+}
@@ -0,0 +1,48 @@
+package uploadbig
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestProbeCapabilities(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.URL.Path == "/tus" {
+			w.Header().Set("Tus-Resumable", "1.0.0")
+			w.Header().Set("Tus-Version", "1.0.0")
+			w.Header().Set("Tus-Extension", "creation, termination")
+			w.Header().Set("Tus-Max-Size", "1000")
+		}
+	})
+
+	u := New("PUT", server.URL+"/tus", "", server.Client(), 1000, nil)
+	capabilities, err := u.ProbeCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Capabilities{
+		Resumable:  true,
+		TusVersion: "1.0.0",
+		Extensions: []string{"creation", "termination"},
+		MaxSize:    1000,
+	}
+	if !reflect.DeepEqual(capabilities, want) {
+		t.Fatalf("capabilities %+v, want %+v", capabilities, want)
+	}
+
+	u = New("PUT", server.URL+"/plain", "", server.Client(), 1000, nil)
+	capabilities, err = u.ProbeCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if capabilities.Resumable {
+		t.Fatalf("capabilities %+v, want not resumable", capabilities)
+	}
+	if got := server.Requests()[0].Method; got != http.MethodOptions {
+		t.Fatalf("probe method %s, want OPTIONS", got)
+	}
+	if got := server.Headers("Tus-Resumable"); got[0] != TusVersion {
+		t.Fatalf("probe sent Tus-Resumable %q", got[0])
+	}
+}
@@ -2,14 +2,20 @@ package uploadbig
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
 	"log"
-	"math"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const MB = 1048576
@@ -22,18 +28,160 @@ type Logger struct {
 
 // UploadData structure
 type UploadData struct {
-	client    *http.Client
-	method    string
-	url       string
-	filePath  string
-	id        string
-	chunkSize int
-	file      *os.File
-	Status    UploadStatus
-	logger    Logger
-}
-
-// UploadStatus holds the data about uploadFile
+	sampledSize int64 // accessed atomically, first for 64-bit alignment
+
+	client     *http.Client
+	method     string
+	url        string
+	filePath   string
+	id         string
+	chunkSize  int
+	file       chunkSource
+	reader     io.Reader
+	fromReader bool
+	size       int64
+	Status     UploadStatus
+	logger     Leveled
+
+	slowChunkTimeout time.Duration
+	timeoutBase      time.Duration
+	timeoutMinRate   int64
+	replicaURLs      []string
+	quorum           int
+	flushEvery       int
+	flushRequest     FlushRequest
+	plan             []ChunkSpec
+	chunks           []ChunkSpec
+	statePath        string
+	events           chan Event
+	hmacSecret       []byte
+	hmacHeader       string
+	hmacMessage      HMACMessageFunc
+	separateParts    bool
+	partURLFunc      PartURLFunc
+	partNameFunc     PartNameFunc
+	partNames        []string
+	minPartSize      int
+	probeMethod      string
+	probeURL         string
+
+	contentRangePrefix   string
+	contentRangeSuffix   string
+	lastChunkRangeSuffix string
+
+	digestAlgorithms      []string
+	checksumHeader        string
+	checksumFunc          ChecksumFunc
+	diagnostic            bool
+	diagnosticFailed      bool
+	partResults           []PartResult
+	treeHashEnabled       bool
+	treeHash              string
+	treeLeaves            [][sha256.Size]byte
+	treeWait              sync.WaitGroup
+	verifySize            bool
+	mmap                  bool
+	remoteTotalSize       int64
+	recorder              Recorder
+	partCommitted         func(chunk ChunkSpec)
+	capabilityMethod      string
+	capabilityURL         string
+	compression           bool
+	compressionMinSize    int
+	codec                 Codec
+	multipartField        string
+	multipartFields       map[string]string
+	sink                  ChunkSink
+	commitRequest         *FlushRequest
+	commitOrder           CommitOrder
+	commits               *commitSequencer
+	finalizeRequest       *FlushRequest
+	manifestEncoder       ManifestEncoder
+	parts                 []PartInfo
+	spillDir              string
+	requireOffsetEcho     bool
+	host                  string
+	correlationID         string
+	contentRangeFunc      ContentRangeFunc
+	prefetchLast          bool
+	prefetched            *prefetchedChunk
+	progressInterval      time.Duration
+	lastProgress          time.Time
+	readWrap              func(read ReadFunc) ReadFunc
+	sseHeaders            map[string]string
+	failure               error
+	additionalHeaders     map[string]string
+	contentHash           func() hash.Hash
+	failFast              bool
+	progressHandler       ProgressFunc
+	readerCloser          io.Closer
+	sessionMaxAge         time.Duration
+	sessionStarted        time.Time
+	attempts              int
+	retryBackoff          RetryBackoff
+	retryBackoffSet       bool
+	progressBar           *progressBar
+	offsetCompletion      bool
+	maxRetryAfter         time.Duration
+	completed             *CompletedBitmap
+	transferredSize       CalculateTransferredSize
+	offsetMode            ResponseOffsetMode
+	offsetField           string
+	transferredFromHeader bool
+	contentTypeFunc       ContentTypeFunc
+	contentType           string
+	sourceReadTimeout     time.Duration
+	resumeStrategy        ResumeStrategy
+	fileName              string
+	requestMutator        RequestMutator
+	successCodes          []int
+	sessionIDHandler      func(id string)
+	schedule              []TimeWindow
+	tus                   bool
+	tusUploadURL          string
+	overallTimeout        time.Duration
+	parentCtx             context.Context
+	stopTimeout           context.CancelFunc
+	clockNow              func() time.Time
+	clockAfter            func(d time.Duration) <-chan time.Time
+	concurrency           int
+	eventsMutex           sync.Mutex
+	verifyURL             string
+	verifyDigest          hash.Hash
+	verifyHashed          int64
+	bandwidth             *BandwidthLimiter
+	expectContinue        bool
+	limiter               *Limiter
+	fallbackSingleShot    bool
+	readerWrapper         func(source io.Reader) io.Reader
+	wrapped               io.Reader
+	wrappedOffset         int64
+	statusMutex           sync.Mutex
+	reprobeEvery          int
+	reprobeInterval       time.Duration
+	reprobeJitter         time.Duration
+	nextReprobe           time.Time
+	partsSinceReprobe     int
+	idempotencyHeader     string
+	idempotencyMode       IdempotencyMode
+	crc32cHeader          string
+	crc32c                uint32
+	offsetProbeMethod     string
+	offsetProbeURL        string
+	realignments          int
+	samplers              []speedSampler
+	samplersStop          chan struct{}
+	samplersWait          sync.WaitGroup
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	runMutex sync.Mutex
+	running  chan struct{}
+	runErr   error
+}
+
+// UploadStatus holds the data about uploadFile.
+// PartsTransferred counts the parts accepted by the server.
 type UploadStatus struct {
 	Size                 int64
 	SizeTransferred      int64
@@ -41,93 +189,427 @@ type UploadStatus struct {
 	PartsTransferred     uint64
 	IsDone               bool
 	TransferredException bool
+
+	// The response to the last accepted chunk, e.g. the server's resource
+	// URL or ID returned with the final chunk
+	LastStatusCode     int
+	LastResponseHeader http.Header
+	LastResponseBody   string
+}
+
+// Snapshot returns a copy of the status that is safe to take from another
+// goroutine while the upload runs. Reading Status directly is only safe on
+// the goroutine running the upload or after it has returned.
+func (c *UploadData) Snapshot() UploadStatus {
+	c.statusMutex.Lock()
+	defer c.statusMutex.Unlock()
+	return c.Status
+}
+
+// BytesRemaining returns the bytes not transferred yet, never negative
+func (s UploadStatus) BytesRemaining() int64 {
+	if s.SizeTransferred >= s.Size {
+		return 0
+	}
+	return s.Size - s.SizeTransferred
+}
+
+// Remaining returns the bytes not transferred yet, the same as BytesRemaining
+func (s UploadStatus) Remaining() int64 {
+	return s.BytesRemaining()
+}
+
+// Percent returns the transferred share of the size from 0 to 100. An empty
+// upload is at 0 until it is done and at 100 after.
+func (s UploadStatus) Percent() float64 {
+	if s.Size <= 0 {
+		if s.IsDone && !s.TransferredException {
+			return 100
+		}
+		return 0
+	}
+	if s.SizeTransferred >= s.Size {
+		return 100
+	}
+	return float64(s.SizeTransferred) * 100 / float64(s.Size)
+}
+
+// PartsRemaining returns the parts not transferred yet, never wrapping
+// around below zero
+func (s UploadStatus) PartsRemaining() uint64 {
+	if s.PartsTransferred >= s.Parts {
+		return 0
+	}
+	return s.Parts - s.PartsTransferred
 }
 
 // New creates new instance
 func New(method string, url string, filePath string, client *http.Client, chunkSize int,
 	logger *Logger) *UploadData {
 
-	if logger == nil {
-		logger = &Logger{
-			DebugLog: log.New(NewNullWriter(), "DEBUG\t", log.Ldate|log.Ltime|log.Lshortfile),
-			InfoLog:  log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
-			ErrorLog: log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+	opts := []Option{WithFile(filePath), WithClient(client), WithChunkSize(chunkSize)}
+	if logger != nil {
+		opts = append(opts, WithLogger(printLogger{logger}))
+	}
+	return NewUploader(method, url, opts...)
+}
+
+// SetContext makes the upload stop when ctx is cancelled or its deadline
+// passes: the in-flight chunk request is aborted, no further chunks are sent
+// and Init returns ctx's error. It must be called before Init.
+func (c *UploadData) SetContext(ctx context.Context) {
+	c.cancel()
+	c.ctx, c.cancel = context.WithCancel(ctx)
+}
+
+// SetAdditionalHeaders adds headers, e.g. authorization, to every request
+// of the upload. Headers set by the upload itself or in a FlushRequest take
+// precedence.
+func (c *UploadData) SetAdditionalHeaders(headers map[string]string) {
+	c.additionalHeaders = make(map[string]string, len(headers))
+	for name, value := range headers {
+		c.additionalHeaders[name] = value
+	}
+}
+
+// SetSlowChunkTimeout sets the time limit for a single chunk request.
+// A chunk exceeding it is cancelled and retried without affecting the rest
+// of the upload. Zero disables the limit.
+func (c *UploadData) SetSlowChunkTimeout(timeout time.Duration) {
+	c.slowChunkTimeout = timeout
+}
+
+// SetScaledChunkTimeout makes the chunk time limit grow with the chunk:
+// base plus the time to send the chunk at minRate bytes per second. It
+// replaces the limit set by SetSlowChunkTimeout.
+func (c *UploadData) SetScaledChunkTimeout(base time.Duration, minRate int64) {
+	c.timeoutBase = base
+	c.timeoutMinRate = minRate
+}
+
+// SetContentRangeAffixes wraps every generated Content-Range value in prefix
+// and suffix. The last chunk gets lastChunkSuffix instead of suffix when it
+// is not empty.
+func (c *UploadData) SetContentRangeAffixes(prefix string, suffix string, lastChunkSuffix string) {
+	c.contentRangePrefix = prefix
+	c.contentRangeSuffix = suffix
+	c.lastChunkRangeSuffix = lastChunkSuffix
+}
+
+// ContentRangeFunc builds the Content-Range of the part index covering the
+// bytes from to to inclusive out of total, counted in the remote object
+// when SetRemoteTotalSize is set
+type ContentRangeFunc func(sessionID string, index uint64, from int64, to int64, total int64) string
+
+// SetContentRangeFunc makes rangeFunc build every Content-Range value, for
+// backends needing more than a prefix and suffix (bytes 0-1023/2048;instance=abc).
+// It replaces the ranges of the plan and the affixes.
+func (c *UploadData) SetContentRangeFunc(rangeFunc ContentRangeFunc) {
+	c.contentRangeFunc = rangeFunc
+}
+
+// SetSuccessCodes makes the chunk responses with one of codes accepted
+// instead of any 2xx, e.g. 308 Resume Incomplete of the Google resumable
+// protocol next to 200 and 201. No codes restores the default.
+func (c *UploadData) SetSuccessCodes(codes ...int) {
+	c.successCodes = append([]int(nil), codes...)
+}
+
+func (c *UploadData) isSuccessCode(statusCode int) bool {
+	if len(c.successCodes) == 0 {
+		return statusCode >= 200 && statusCode <= 299
+	}
+	for _, code := range c.successCodes {
+		if code == statusCode {
+			return true
 		}
 	}
+	return false
+}
+
+// RequestMutator changes a chunk request right before it is sent
+type RequestMutator func(request *http.Request) error
 
-	uploadData := &UploadData{
-		client:    client,
-		method:    method,
-		url:       url,
-		filePath:  filePath,
-		id:        generateSessionID(),
-		chunkSize: chunkSize,
-		logger:    *logger,
-		Status: UploadStatus{
-			Size:                 0,
-			SizeTransferred:      0,
-			Parts:                0,
-			PartsTransferred:     0,
-			IsDone:               false,
-			TransferredException: false,
-		},
+// SetRequestMutator makes every attempt of a chunk request, and the
+// single-shot request, go through mutate once its headers and body are
+// set, e.g. to sign it with AWS SigV4 or to add a fresh bearer token. An
+// error fails the attempt, which is retried.
+func (c *UploadData) SetRequestMutator(mutate RequestMutator) {
+	c.requestMutator = mutate
+}
+
+// SetFileName sets the file name of the Content-Disposition header, which
+// otherwise is the base of the file path. A reader-backed upload has no
+// path to take it from.
+func (c *UploadData) SetFileName(name string) {
+	c.fileName = name
+}
+
+// SetContentType declares contentType, e.g. image/jpeg, instead of
+// application/octet-stream for the content. A Content-Type set by
+// SetContentTypeFunc for a part takes precedence. Neither applies to tus
+// uploads, which send application/offset+octet-stream.
+func (c *UploadData) SetContentType(contentType string) {
+	c.contentType = contentType
+}
+
+// ContentTypeFunc returns the Content-Type of part index, empty for the
+// default set by SetContentType or application/octet-stream
+type ContentTypeFunc func(index uint64) string
+
+// SetContentTypeFunc lets every part declare its own media type, e.g. a
+// JSON manifest in part 0 followed by binary parts. Multipart bodies keep
+// their multipart Content-Type.
+func (c *UploadData) SetContentTypeFunc(contentTypeFunc ContentTypeFunc) {
+	c.contentTypeFunc = contentTypeFunc
+}
+
+// SetReplicas makes every chunk go to all urls concurrently instead of the
+// main url. A chunk is committed when at least quorum replicas accept it;
+// quorum must be between 1 and the number of urls.
+func (c *UploadData) SetReplicas(urls []string, quorum int) error {
+	if quorum < 1 || quorum > len(urls) {
+		return fmt.Errorf("quorum %d is outside of [1, %d]", quorum, len(urls))
 	}
+	c.replicaURLs = urls
+	c.quorum = quorum
+	return nil
+}
 
-	return uploadData
+// SetRequireOffsetEcho controls how a successful response with an empty
+// body is read. By default the whole part is taken as received; with
+// require set such a response fails the attempt, for servers that always
+// echo the received range.
+func (c *UploadData) SetRequireOffsetEcho(require bool) {
+	c.requireOffsetEcho = require
 }
 
-// Init method initializes uploadFile
+// Init method initializes uploadFile. It returns the error that stopped the
+// upload, nil when the whole file has been transferred.
 func (c *UploadData) Init() error {
-	fileStat, err := os.Stat(c.filePath)
+	return c.run(false)
+}
+
+func (c *UploadData) run(resume bool) (err error) {
+	c.startRun()
+	defer func() {
+		c.endRun(err)
+	}()
+
+	size, err := c.sourceSize()
 	if c.checkError(err) {
 		return err
 	}
 
-	c.Status.Size = fileStat.Size()
-	c.Status.Parts = uint64(math.Ceil(float64(c.Status.Size) / float64(c.chunkSize)))
+	// a previous run, failed or not, leaves nothing behind
+	c.statusMutex.Lock()
+	c.Status = UploadStatus{Size: size}
+	c.statusMutex.Unlock()
+	if c.remoteTotalSize > 0 && c.remoteTotalSize < size {
+		err = fmt.Errorf("remote total size %d is less than upload size %d", c.remoteTotalSize, size)
+		c.checkError(err)
+		return err
+	}
+	keepState := c.statePath != "" && !c.fromReader
+	if keepState && resume {
+		err = c.restoreSession()
+		if c.checkError(err) {
+			return err
+		}
+	}
+	if c.contentHash != nil {
+		id, err := c.contentHashID()
+		if c.checkError(err) {
+			return err
+		}
+		c.setSessionID(id)
+	}
+	if keepState {
+		err = c.saveRunState()
+		if c.checkError(err) {
+			return err
+		}
+	}
+	if c.plan != nil {
+		err = validatePlan(c.plan, 0, c.Status.Size)
+		if c.checkError(err) {
+			return err
+		}
+		c.chunks = c.plan
+	} else {
+		c.chunks = c.defaultPlan(c.Status.Size)
+		// a planning bug fails here rather than as a short read of the last part
+		err = validatePlan(c.chunks, 0, c.Status.Size)
+		if err != nil {
+			err = fmt.Errorf("computed plan is invalid: %w", err)
+			c.checkError(err)
+			return err
+		}
+	}
+	c.statusMutex.Lock()
+	c.Status.Parts = uint64(len(c.chunks))
+	c.statusMutex.Unlock()
+	c.partNames = nil
+	c.parts = nil
+	c.crc32c = 0
+	c.lastProgress = time.Time{}
+	c.failure = nil
+	c.realignments = 0
+	c.startCommits()
+	c.startVerify()
+	err = c.startTreeHash()
+	if c.checkError(err) {
+		return err
+	}
 
-	c.file, err = os.Open(c.filePath)
+	err = c.openSource()
 	if c.checkError(err) {
 		return err
 	}
 
 	defer c.Close()
-	c.uploadFile()
-	c.logger.InfoLog.Printf("Done\n")
+	if c.tus && !resume {
+		err = c.createTus(c.contentFileName())
+	} else if c.tus && c.tusUploadURL == "" {
+		err = errors.New("resuming a tus upload needs its URL, see SetTusUploadURL")
+	}
+	if c.checkError(err) {
+		return err
+	}
+	start := uint64(0)
+	if resume {
+		start, err = c.resumePosition()
+		if c.checkError(err) {
+			return err
+		}
+	}
+	if start == 0 && c.singleShotRefused() {
+		c.uploadSingleShot(nil)
+	} else {
+		c.uploadFile(start)
+	}
+	c.infof("Done")
+	if c.Status.TransferredException {
+		if c.ctx.Err() != nil {
+			return c.runError()
+		}
+		if c.failure != nil {
+			return c.failure
+		}
+		return fmt.Errorf("upload %s failed", c.id)
+	}
 	return nil
 }
 
+// Close releases the file opened by Init, Resume or Append, which close it
+// themselves before returning. Calling it again, or before any upload, does
+// nothing.
 func (c *UploadData) Close() {
-	c.logger.DebugLog.Printf("Close file %s\n", c.filePath)
+	c.waitPrefetch()
+	if c.readerCloser != nil {
+		c.readerCloser.Close()
+		c.readerCloser = nil
+	}
+	if c.file == nil {
+		return
+	}
+	c.debugf("Close file %s", c.filePath)
 	err := c.file.Close()
 	if err != nil {
-		c.logger.ErrorLog.Println(err)
+		c.errorf("%v", err)
 	}
+	// forgetting the file makes Close safe to call again
+	c.file = nil
 }
 
 func (c *UploadData) checkError(err error) bool {
 	if err != nil {
-		c.logger.ErrorLog.Println(err)
+		c.errorf("%v", err)
+		if c.failure == nil {
+			c.failure = err
+		}
 		c.uploadDone(true)
 	}
 	return err != nil
 }
 
-func (c *UploadData) uploadFile() {
-	i := uint64(0)
+func (c *UploadData) uploadFile(start uint64) {
+	if c.concurrent() {
+		c.uploadConcurrently(start)
+		return
+	}
+	i := start
+	c.scheduleReprobe()
 
 	for !c.Status.IsDone {
+		committed := c.Status.PartsTransferred
+		if c.ctx.Err() != nil {
+			c.errorf("Upload %s stopped: %v", c.id, c.ctx.Err())
+			c.uploadDone(true)
+			return
+		}
+		if i < c.Status.Parts && c.sessionExpired() {
+			c.checkError(ErrSessionExpired)
+			return
+		}
+		if i < c.Status.Parts && c.checkError(c.waitSchedule()) {
+			return
+		}
+		if i < c.Status.Parts && c.reprobeDue() {
+			if c.checkError(c.reprobe(i)) {
+				return
+			}
+		}
 		c.uploadChunk(i)
+		if !c.Status.IsDone && c.Status.PartsTransferred > committed && c.needFlush(c.Status.PartsTransferred) {
+			if c.checkError(c.flush()) {
+				return
+			}
+		}
 		i = i + 1
 	}
 }
 
 type CalculateTransferredSize func(body string, partSize int, status UploadStatus) (int64, error)
 
-func calculateTransferredSize(body string, partSize int, status UploadStatus) (int64, error) {
+// SetTransferredSizeCalculator replaces the parsing of the "from-to/total"
+// response body with calc, which returns the bytes of the part the server
+// has accepted
+func (c *UploadData) SetTransferredSizeCalculator(calc CalculateTransferredSize) {
+	c.transferredSize = calc
+}
+
+// SetTransferredSizeFromHeader reads the accepted bytes of a part from the
+// offset in the Upload-Offset or Range response header, as the Google
+// resumable protocol sends it, instead of the body. The offset counts from
+// the start of the upload. Responses without either header are read as
+// before.
+func (c *UploadData) SetTransferredSizeFromHeader(enabled bool) {
+	c.transferredFromHeader = enabled
+}
+
+func (c *UploadData) partTransferredSize(response chunkResponse, partSize int) (int64, error) {
+	if (c.transferredFromHeader || c.tus) && (response.header.Get("Upload-Offset") != "" || response.header.Get("Range") != "") {
+		offset, err := parseOffsetHeaders(response.header)
+		if err != nil {
+			return 0, err
+		}
+		return c.localOffset(offset) - c.Status.SizeTransferred, nil
+	}
+	value, err := c.responseValue(response, partSize)
+	if err != nil || c.offsetMode != OffsetAbsolute {
+		return value, err
+	}
+	return c.localOffset(value) - c.chunks[0].Offset - c.Status.SizeTransferred, nil
+}
+
+func calculateTransferredSize(body string, partSize int, status UploadStatus, requireEcho bool) (int64, error) {
 	if body != "" {
 		return parseBody(body)
 	}
+	if requireEcho {
+		return 0, errors.New("response body has no received range")
+	}
 
 	result := int64(partSize)
 	if (result + status.SizeTransferred) > status.Size {
@@ -151,99 +633,498 @@ func parseBody(body string) (int64, error) {
 
 func (c *UploadData) uploadDone(isException bool) {
 	if isException {
-		c.logger.ErrorLog.Printf("Upload process done by exception\n")
+		c.errorf("Upload process done by exception")
 	} else {
-		c.logger.InfoLog.Printf("Upload process done\n")
+		c.infof("Upload process done")
 	}
+	c.statusMutex.Lock()
 	c.Status.IsDone = true
 	c.Status.TransferredException = isException
+	c.statusMutex.Unlock()
+
+	if isException {
+		c.emit(Aborted{Status: c.Status})
+	} else {
+		c.emit(Done{Status: c.Status})
+	}
+	c.closeEvents()
 }
 
 func (c *UploadData) uploadChunk(i uint64) {
 	if i == c.Status.Parts {
-		c.logger.InfoLog.Printf("Upload %s: done\n", c.id)
-		c.uploadDone(false)
+		if c.checkError(c.checkCommitted()) {
+			return
+		}
+		if c.checkError(c.checkReaderDrained()) {
+			return
+		}
+		if c.checkError(c.finalizeSink()) {
+			return
+		}
+		if c.checkError(c.finalize()) {
+			return
+		}
+		if c.checkError(c.verifyByDownload()) {
+			return
+		}
+		c.infof("Upload %s: done", c.id)
+		c.finishTreeHash()
+		c.uploadDone(c.diagnosticFailed)
 	} else if c.Status.TransferredException {
-		c.logger.ErrorLog.Printf("ERROR. Transfered exception\n")
+		c.errorf("ERROR. Transfered exception")
 	} else {
-		fileName := filepath.Base(c.filePath)
-		partSize := int(math.Ceil(math.Min(float64(c.chunkSize), float64(c.Status.Size-int64(i*uint64(c.chunkSize))))))
-		if partSize <= 0 {
+		upload := c.prepareChunk(i)
+		if upload == nil {
 			return
 		}
+		c.startPrefetch(i)
+		c.sendAttempts(upload)
+		c.completeChunk(upload)
+	}
+}
+
+// chunkUpload is a chunk ready to be sent and the outcome of sending it
+type chunkUpload struct {
+	index        uint64
+	chunk        ChunkSpec
+	url          string
+	fileName     string
+	contentRange string
+	headers      map[string]string
+	data         []byte
+	body         []byte
+	spillPath    string
+
+	isSuccess  bool
+	response   chunkResponse
+	err        error
+	errorCount int
+}
+
+// contentFileName returns the file name of the Content-Disposition header
+func (c *UploadData) contentFileName() string {
+	if c.fileName != "" {
+		return c.fileName
+	}
+	return filepath.Base(c.filePath)
+}
+
+// prepareChunk reads and encodes chunk i. It returns nil when the chunk
+// has been skipped or has failed.
+func (c *UploadData) prepareChunk(i uint64) *chunkUpload {
+	fileName := c.contentFileName()
+	chunk := c.chunks[i]
+	partSize := chunk.Length
+
+	contentRange := c.decorateContentRange(c.chunks, i)
+	url := c.chunkURL()
+	if c.tus {
+		contentRange = ""
+	}
+	if c.separateParts {
+		fileName = c.partName(fileName, i)
+		url = c.partURL(fileName, i)
+		contentRange = ""
+	}
 
-		partBuffer := make([]byte, partSize)
-		readBytes, err := c.file.Read(partBuffer)
+	if c.completed != nil && c.completed.Test(i) {
+		err := c.skipCompleted(chunk)
 		if err != nil {
-			c.logger.ErrorLog.Println(err)
-			c.uploadDone(true)
-			return
+			c.errorf("%v", err)
+			c.partFailed(i, contentRange, chunkResponse{}, err)
 		}
-		c.logger.DebugLog.Printf("Read %d bytes", readBytes)
+		return nil
+	}
 
-		contentRange := generateContentRange(i, c.chunkSize, partSize, c.Status.Size)
+	partBuffer := make([]byte, partSize)
+	readBytes, err := c.readChunk(chunk, partBuffer)
+	if err != nil {
+		c.errorf("%v", err)
+		c.partFailed(i, contentRange, chunkResponse{}, err)
+		return nil
+	}
+	c.debugf("Read %d bytes", readBytes)
+	c.hashTreeLeaves(chunk.Offset, partBuffer)
+	c.updateCRC32C(partBuffer)
+	c.hashVerified(chunk.Offset, partBuffer)
 
-		var isSuccess = false
-		var responseBody = ""
-		var errorCount = 0
+	body, encoding, err := c.encodeChunk(partBuffer)
+	contentType := ""
+	if c.multipartField != "" {
+		body, contentType, err = c.multipartBody(partBuffer, fileName)
+		encoding = ""
+	}
+	if err != nil {
+		c.errorf("%v", err)
+		c.partFailed(i, contentRange, chunkResponse{}, err)
+		return nil
+	}
 
-		for !isSuccess && errorCount < 3 {
-			isSuccess, responseBody, err = httpRequest(c.method, c.url, c.client, c.id, partBuffer, contentRange, fileName, c.logger.DebugLog)
-			c.logger.DebugLog.Printf("isSuccess: %t \n", isSuccess)
+	headers := c.chunkHeaders(i, contentRange, body)
+	if encoding != "" {
+		headers["Content-Encoding"] = encoding
+	}
+	if contentType == "" && c.contentTypeFunc != nil {
+		contentType = c.contentTypeFunc(i)
+	}
+	if contentType == "" {
+		contentType = c.contentType
+	}
+	// tus requires its own media type for every PATCH
+	if contentType != "" && !c.tus {
+		headers["Content-Type"] = contentType
+	}
+
+	spillPath, err := c.spillChunk(body)
+	if err != nil {
+		c.errorf("%v", err)
+		c.partFailed(i, contentRange, chunkResponse{}, err)
+		return nil
+	}
+	data := partBuffer
+	if spillPath != "" {
+		body = nil
+		// the single-shot fallback can read unencoded bytes back from the
+		// spill file, encoded ones are kept only if it may need them
+		if encoding == "" && c.multipartField == "" || !c.fallbackSingleShot || i != 0 {
+			data = nil
+		}
+	}
+
+	return &chunkUpload{
+		index:        i,
+		chunk:        chunk,
+		url:          url,
+		fileName:     fileName,
+		contentRange: contentRange,
+		headers:      headers,
+		data:         data,
+		body:         body,
+		spillPath:    spillPath,
+	}
+}
+
+// sendAttempts sends the chunk until it is accepted or the attempts run
+// out. It doesn't change the state of the upload, so concurrent uploads
+// run it on their own goroutines.
+func (c *UploadData) sendAttempts(upload *chunkUpload) {
+	i := upload.index
+	partSize := upload.chunk.Length
+	c.emit(ChunkStarted{Index: i, ContentRange: upload.contentRange})
+
+	var isSuccess = false
+	var response chunkResponse
+	var errorCount = 0
+	var err error
+
+	for !isSuccess && errorCount < c.maxAttempts() && c.ctx.Err() == nil {
+		attemptBody := upload.body
+		if upload.spillPath != "" {
+			attemptBody, err = ioutil.ReadFile(upload.spillPath)
 			if err != nil {
-				c.logger.ErrorLog.Println(err)
-				isSuccess = false
-			}
-			if !isSuccess {
-				errorCount++
+				c.errorf("%v", err)
+				break
 			}
 		}
 
-		if isSuccess {
-			transferredBytes, err1 := calculateTransferredSize(responseBody, partSize, c.Status)
-			if !c.checkError(err1) {
-				c.Status.SizeTransferred += transferredBytes
-				c.Status.PartsTransferred = i + 1
+		if !c.throttle(len(attemptBody)) || !c.acquireSlot() {
+			err = c.ctx.Err()
+			break
+		}
+		ctx, cancel := c.chunkContext(partSize)
+		isSuccess, response, err = c.chunkSender().send(ctx, chunkAttempt{
+			url:          upload.url,
+			headers:      c.attemptHeaders(upload.headers, i),
+			offset:       upload.chunk.Offset,
+			body:         attemptBody,
+			contentRange: upload.contentRange,
+			fileName:     upload.fileName,
+		})
+		c.releaseSlot()
+		if ctx.Err() == context.DeadlineExceeded {
+			c.errorf("Part %d exceeded slow chunk timeout %v, retry", i, c.chunkTimeout(partSize))
+		}
+		cancel()
+		c.debugf("isSuccess: %t ", isSuccess)
+		if err != nil {
+			c.errorf("%v", err)
+			isSuccess = false
+		}
+		if !isSuccess {
+			errorCount++
+			if err == nil {
+				err = fmt.Errorf("part %d was not accepted, HTTP code %d", i, response.statusCode)
 			}
+			c.emit(ChunkFailed{Index: i, Attempt: errorCount, Err: err})
+		}
+		if response.statusCode == http.StatusRequestedRangeNotSatisfiable {
+			break
+		}
+		if errors.Is(err, ErrCertPinMismatch) {
+			break
+		}
+		if response.statusCode == http.StatusNotImplemented && c.fallbackSingleShot {
+			break
+		}
+		if !isSuccess && !c.waitRetry(errorCount, c.retryAfter(response)) {
+			break
+		}
+	}
+
+	upload.isSuccess = isSuccess
+	upload.response = response
+	upload.err = err
+	upload.errorCount = errorCount
+}
+
+// completeChunk applies the outcome of sending the chunk to the upload
+func (c *UploadData) completeChunk(upload *chunkUpload) {
+	if c.checkError(c.releaseSpill(upload)) {
+		return
+	}
+	if c.Status.TransferredException {
+		return
+	}
+	i := upload.index
+	chunk := upload.chunk
+	partSize := chunk.Length
+	contentRange := upload.contentRange
+	response := upload.response
+	err := upload.err
+
+	if !c.concurrent() && c.fallsBackToSingleShot(upload) {
+		c.uploadSingleShot(upload.data)
+		return
+	}
+	if !upload.isSuccess && response.statusCode == http.StatusRequestedRangeNotSatisfiable {
+		if c.concurrent() {
+			c.partFailed(i, contentRange, response, ErrRangeNotSatisfiable)
+			return
+		}
+		c.handleRangeNotSatisfiable(i, contentRange, response)
+		return
+	}
+
+	if upload.isSuccess {
+		transferredBytes, err1 := c.partTransferredSize(response, partSize)
+		if err1 == nil && c.commits != nil {
+			err1 = c.commits.done(chunk)
+		}
+		if err1 != nil {
+			c.errorf("%v", err1)
+			c.partFailed(i, contentRange, response, err1)
 		} else {
-			c.uploadDone(true)
+			c.setSizeTransferred(c.Status.SizeTransferred + transferredBytes)
+			c.statusMutex.Lock()
+			c.Status.PartsTransferred++
+			c.Status.LastStatusCode = response.statusCode
+			c.Status.LastResponseHeader = response.header
+			c.Status.LastResponseBody = response.body
+			c.statusMutex.Unlock()
+			c.partsSinceReprobe++
+			c.realignments = 0
+			if c.separateParts {
+				c.partNames = append(c.partNames, upload.fileName)
+			}
+			c.parts = append(c.parts, PartInfo{Number: int(i) + 1, ETag: response.header.Get("ETag"), Size: partSize})
+			c.emit(ChunkCompleted{Index: i, Bytes: transferredBytes})
+			c.emitProgress()
+			c.recordPart(i, contentRange, response, nil)
+			if c.completed != nil {
+				c.completed.Set(i)
+			}
+			if c.partCommitted != nil {
+				c.partCommitted(chunk)
+			}
+			if (c.offsetCompletion || c.tus) && !c.concurrent() && c.checkError(c.followServerOffset(i, response.header)) {
+				return
+			}
+			if c.offsetMode == OffsetAbsolute && !c.concurrent() && c.checkError(c.followOffset(i, c.chunks[0].Offset+c.Status.SizeTransferred)) {
+				return
+			}
+		}
+	} else {
+		if upload.errorCount > 0 && !c.failFast {
+			err = fmt.Errorf("part %d failed after %d attempts: %w", i, upload.errorCount, err)
+		}
+		c.partFailed(i, contentRange, response, err)
+	}
+
+	c.debugf("Part: %d of: %d", c.Status.PartsTransferred, c.Status.Parts)
+}
+
+// decorateContentRange returns the Content-Range sent for chunk i of plan
+func (c *UploadData) decorateContentRange(plan []ChunkSpec, i uint64) string {
+	chunk := plan[i]
+	if c.contentRangeFunc != nil {
+		last := plan[len(plan)-1]
+		total := last.Offset + int64(last.Length)
+		from := c.remoteBase(total) + chunk.Offset
+		if c.remoteTotalSize > 0 {
+			total = c.remoteTotalSize
+		}
+		return c.contentRangeFunc(c.id, chunk.Index, from, from+int64(chunk.Length)-1, total)
+	}
+
+	suffix := c.contentRangeSuffix
+	if i+1 == uint64(len(plan)) && c.lastChunkRangeSuffix != "" {
+		suffix = c.lastChunkRangeSuffix
+	}
+	return c.contentRangePrefix + chunk.ContentRange + suffix
+}
+
+func (c *UploadData) chunkHeaders(index uint64, contentRange string, part []byte) map[string]string {
+	headers := map[string]string{}
+	if c.hmacHeader != "" {
+		headers[c.hmacHeader] = c.signChunk(index, contentRange)
+	}
+	if len(c.digestAlgorithms) > 0 {
+		headers["Digest"] = digestHeader(c.digestAlgorithms, part)
+	}
+	if c.checksumHeader != "" {
+		headers[c.checksumHeader] = c.checksumFunc(part)
+	}
+	if c.expectContinue {
+		headers["Expect"] = "100-continue"
+	}
+	if c.tus {
+		tusHeaders(headers, c.chunks[index].Offset)
+	}
+	if c.crc32cHeader != "" && index+1 == c.Status.Parts {
+		headers[c.crc32cHeader] = encodeCRC32C(c.crc32c)
+	}
+	return c.sharedHeaders(headers)
+}
+
+// httpSender is the chunkSender of an upload without a sink, it sends the
+// chunks to the upload URL or to the replicas
+type httpSender struct {
+	c *UploadData
+}
+
+func (s httpSender) send(ctx context.Context, attempt chunkAttempt) (bool, chunkResponse, error) {
+	c := s.c
+	if len(c.replicaURLs) == 0 {
+		return httpRequest(ctx, c.chunkMethod(), attempt.url, attempt.headers, c.client, c.id, attempt.body, attempt.contentRange, attempt.fileName, c.recorder, c.requestMutator, c.isSuccessCode, c.debugf)
+	}
+
+	type replicaResult struct {
+		isSuccess bool
+		response  chunkResponse
+		err       error
+	}
+
+	results := make([]replicaResult, len(c.replicaURLs))
+	var wg sync.WaitGroup
+	for n, replicaURL := range c.replicaURLs {
+		wg.Add(1)
+		go func(n int, url string) {
+			defer wg.Done()
+			isSuccess, response, err := httpRequest(ctx, c.chunkMethod(), url, attempt.headers, c.client, c.id, attempt.body, attempt.contentRange, attempt.fileName, c.recorder, c.requestMutator, c.isSuccessCode, c.debugf)
+			results[n] = replicaResult{isSuccess: isSuccess, response: response, err: err}
+		}(n, replicaURL)
+	}
+	wg.Wait()
+
+	successCount := 0
+	var response chunkResponse
+	for n, result := range results {
+		if result.err != nil {
+			c.errorf("Replica %s: %v", c.replicaURLs[n], result.err)
+		}
+		if result.isSuccess {
+			if successCount == 0 {
+				response = result.response
+			}
+			successCount++
+		} else if successCount == 0 {
+			response = result.response
 		}
+	}
+	c.debugf("  %s replicas succeeded %d of %d, quorum %d", attempt.contentRange, successCount, len(results), c.quorum)
+
+	if successCount < c.quorum {
+		return false, response, fmt.Errorf("quorum not reached: %d of %d replicas succeeded, need %d", successCount, len(results), c.quorum)
+	}
+	return true, response, nil
+}
+
+func (c *UploadData) chunkContext(partSize int) (context.Context, context.CancelFunc) {
+	if timeout := c.chunkTimeout(partSize); timeout > 0 {
+		return context.WithTimeout(c.ctx, timeout)
+	}
+	return context.WithCancel(c.ctx)
+}
 
-		c.logger.DebugLog.Printf("Part: %d of: %d", c.Status.PartsTransferred, c.Status.Parts)
+// chunkResponse is what the server answered to a chunk request
+type chunkResponse struct {
+	statusCode int
+	header     http.Header
+	body       string
+}
+
+func (c *UploadData) chunkTimeout(partSize int) time.Duration {
+	if c.timeoutMinRate > 0 {
+		return c.timeoutBase + time.Duration(int64(partSize)*int64(time.Second)/c.timeoutMinRate)
 	}
+	return c.slowChunkTimeout
 }
 
-func httpRequest(method string,
+func httpRequest(ctx context.Context,
+	method string,
 	url string,
+	additionalHeaders map[string]string,
 	client *http.Client,
 	sessionID string,
 	part []byte,
 	contentRange string,
 	fileName string,
-	debugLogger *log.Logger) (bool, string, error) {
-	request, err := http.NewRequest(method, url, bytes.NewBuffer(part))
+	recorder Recorder,
+	mutate RequestMutator,
+	isSuccess func(statusCode int) bool,
+	debugf func(format string, args ...interface{})) (bool, chunkResponse, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(part))
 	if err != nil {
-		return false, "", err
+		return false, chunkResponse{}, err
 	}
 
 	request.Header.Add("Content-Type", "application/octet-stream")
 	request.Header.Add("Content-Disposition", "attachment; filename=\""+fileName+"\"")
-	request.Header.Add("Content-Range", contentRange)
+	if contentRange != "" {
+		request.Header.Add("Content-Range", contentRange)
+	}
 	request.Header.Add("Session-ID", sessionID)
+	setHeaders(request, additionalHeaders)
+	if mutate != nil {
+		err = mutate(request)
+		if err != nil {
+			return false, chunkResponse{}, err
+		}
+	}
 
 	response, err := client.Do(request)
 	if err != nil {
-		return false, "", err
+		recordExchange(recorder, request, part, chunkResponse{}, err)
+		return false, chunkResponse{}, err
 	}
 
 	statusCode := response.StatusCode
 
-	debugLogger.Printf("  %s HTTP code %d", contentRange, statusCode)
+	debugf("  %s HTTP code %d", contentRange, statusCode)
 	defer response.Body.Close()
 	body, err := ioutil.ReadAll(response.Body)
+	if err == nil && response.ContentLength >= 0 && int64(len(body)) != response.ContentLength {
+		err = fmt.Errorf("response body is %d bytes, Content-Length is %d", len(body), response.ContentLength)
+	} else if err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("response body is truncated, Content-Length is %d", response.ContentLength)
+	}
 	if err != nil {
-		return false, "", err
+		result := chunkResponse{statusCode: statusCode, header: response.Header}
+		recordExchange(recorder, request, part, result, err)
+		return false, result, err
 	}
-	debugLogger.Printf("  Body %v\n", body)
-	return statusCode >= 200 && statusCode <= 299, string(body), nil
+	debugf("  Body %v", body)
+	result := chunkResponse{statusCode: statusCode, header: response.Header, body: string(body)}
+	recordExchange(recorder, request, part, result, nil)
+	return isSuccess(statusCode), result, nil
 }
@@ -0,0 +1,618 @@
+package uploadbig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testContent returns n bytes of a pattern not repeating at chunk sizes
+func testContent(n int) []byte {
+	content := make([]byte, n)
+	for i := range content {
+		content[i] = byte(i % 251)
+	}
+	return content
+}
+
+// testChunk returns chunk i of content split into chunks of chunkSize bytes
+func testChunk(content []byte, i int, chunkSize int) []byte {
+	end := (i + 1) * chunkSize
+	if end > len(content) {
+		end = len(content)
+	}
+	return content[i*chunkSize : end]
+}
+
+// testFile writes testContent(n) to a temporary file and returns its path
+func testFile(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	if err := ioutil.WriteFile(path, testContent(n), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testRequest is a request received by a testServer
+type testRequest struct {
+	Method string
+	URL    string
+	Host   string
+	Header http.Header
+	Body   []byte
+}
+
+// testServer records the requests it receives, then lets handler answer
+// the request number n, counted from 0. A nil handler answers 200.
+type testServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []testRequest
+}
+
+func newTestServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, n int)) *testServer {
+	t.Helper()
+	s := &testServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		s.mutex.Lock()
+		n := len(s.requests)
+		s.requests = append(s.requests, testRequest{r.Method, r.URL.String(), r.Host, r.Header.Clone(), body})
+		s.mutex.Unlock()
+		if handler != nil {
+			handler(w, r, n)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests received so far
+func (s *testServer) Requests() []testRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]testRequest(nil), s.requests...)
+}
+
+// Headers returns the value of header name of every request received
+func (s *testServer) Headers(name string) []string {
+	var values []string
+	for _, request := range s.Requests() {
+		values = append(values, request.Header.Get(name))
+	}
+	return values
+}
+
+// Received reassembles the bodies of the chunk requests by Content-Range
+func (s *testServer) Received(size int) []byte {
+	received := make([]byte, size)
+	for _, request := range s.Requests() {
+		var from, to, total int64
+		if _, err := fmt.Sscanf(request.Header.Get("Content-Range"), "bytes %d-%d/%d", &from, &to, &total); err != nil {
+			continue
+		}
+		copy(received[from:], request.Body)
+	}
+	return received
+}
+
+func TestSlowChunkIsRetried(t *testing.T) {
+	release := make(chan struct{})
+	var stalled int32
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Header.Get("Content-Range") == "bytes 1000-1999/4000" && atomic.AddInt32(&stalled, 1) == 1 {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+	})
+	defer close(release)
+
+	u := New("PUT", server.URL, testFile(t, 4000), server.Client(), 1000, nil)
+	u.SetConcurrency(2)
+	u.SetSlowChunkTimeout(100 * time.Millisecond)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if u.Status.PartsTransferred != 4 {
+		t.Fatalf("parts transferred %d, want 4", u.Status.PartsTransferred)
+	}
+	if got := len(server.Requests()); got != 5 {
+		t.Fatalf("%d requests, want 5", got)
+	}
+	if !bytes.Equal(server.Received(4000), testContent(4000)) {
+		t.Fatal("received content differs")
+	}
+}
+
+func TestReplicasQuorum(t *testing.T) {
+	var urls []string
+	for k := 0; k < 3; k++ {
+		failing := k == 1
+		server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+			if failing {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+		urls = append(urls, server.URL)
+	}
+
+	u := New("PUT", "", testFile(t, 3000), http.DefaultClient, 1000, nil)
+	u.SetMaxRetries(0)
+	if err := u.SetReplicas(urls, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(); err != nil || u.Status.PartsTransferred != 3 {
+		t.Fatalf("quorum 2: %v, %d parts", err, u.Status.PartsTransferred)
+	}
+
+	u = New("PUT", "", testFile(t, 3000), http.DefaultClient, 1000, nil)
+	u.SetMaxRetries(0)
+	if err := u.SetReplicas(urls, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(); err == nil {
+		t.Fatal("quorum 3 succeeded with a failing replica")
+	}
+
+	for _, quorum := range []int{-1, 0, 4} {
+		if err := u.SetReplicas(urls, quorum); err == nil {
+			t.Errorf("quorum %d accepted", quorum)
+		}
+	}
+}
+
+func TestContentRangeAffixes(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	u.SetContentRangeAffixes("chunk ", ";part", ";final")
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"chunk bytes 0-999/3000;part",
+		"chunk bytes 1000-1999/3000;part",
+		"chunk bytes 2000-2999/3000;final",
+	}
+	if got := server.Headers("Content-Range"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+}
+
+func TestScaledChunkTimeout(t *testing.T) {
+	u := New("PUT", "", "", nil, 1000, nil)
+	u.SetSlowChunkTimeout(time.Minute)
+	u.SetScaledChunkTimeout(time.Second, 1000)
+	for _, test := range []struct {
+		partSize int
+		want     time.Duration
+	}{
+		{0, time.Second},
+		{500, 1500 * time.Millisecond},
+		{1000, 2 * time.Second},
+		{10000, 11 * time.Second},
+	} {
+		if got := u.chunkTimeout(test.partSize); got != test.want {
+			t.Errorf("part of %d bytes: timeout %v, want %v", test.partSize, got, test.want)
+		}
+	}
+}
+
+func TestTruncatedResponseIsRetried(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 0 {
+			// the body is cut short of the declared length
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("0-999/1000"))
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 1000), server.Client(), 1000, nil)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(server.Requests()); got != 2 {
+		t.Fatalf("%d requests, want the truncated answer retried", got)
+	}
+	if u.Status.SizeTransferred != 1000 {
+		t.Fatalf("%d bytes transferred, want 1000", u.Status.SizeTransferred)
+	}
+}
+
+func TestRequireOffsetEcho(t *testing.T) {
+	silent := newTestServer(t, nil)
+	echoing := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "0-%d", len(body))
+	})
+
+	for _, test := range []struct {
+		name    string
+		server  *testServer
+		require bool
+		wantErr bool
+	}{
+		{"lenient empty body", silent, false, false},
+		{"strict empty body", silent, true, true},
+		{"strict echo", echoing, true, false},
+	} {
+		u := New("PUT", test.server.URL, testFile(t, 2500), test.server.Client(), 1000, nil)
+		u.SetMaxRetries(0)
+		u.SetRequireOffsetEcho(test.require)
+		err := u.Init()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error %v", test.name, err)
+		}
+		if !test.wantErr && u.Status.SizeTransferred != 2500 {
+			t.Errorf("%s: %d bytes transferred, want 2500", test.name, u.Status.SizeTransferred)
+		}
+	}
+}
+
+func TestContentRangeFunc(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2048), server.Client(), 1024, nil)
+	u.SetContentRangeFunc(func(sessionID string, index uint64, from int64, to int64, total int64) string {
+		return fmt.Sprintf("bytes %d-%d/%d;instance=abc", from, to, total)
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"bytes 0-1023/2048;instance=abc", "bytes 1024-2047/2048;instance=abc"}
+	if got := server.Headers("Content-Range"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 {
+			<-r.Context().Done()
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	u.SetContext(ctx)
+
+	start := time.Now()
+	err := u.Init()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Init returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Init returned after %v", elapsed)
+	}
+	// the chunk in flight is aborted and nothing follows it
+	if got := len(server.Requests()); got != 2 || u.Status.PartsTransferred != 1 {
+		t.Fatalf("%d requests, %d parts transferred", got, u.Status.PartsTransferred)
+	}
+}
+
+func TestInitReturnsTheFailure(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	u := New("PUT", server.URL, testFile(t, 2000), server.Client(), 1000, nil)
+	u.SetMaxRetries(1)
+	err := u.Init()
+	if err == nil {
+		t.Fatal("Init returned nil for a rejected upload")
+	}
+	if want := "part 0 failed after 2 attempts: part 0 was not accepted, HTTP code 403"; err.Error() != want {
+		t.Fatalf("error %q, want %q", err, want)
+	}
+	if !u.Status.TransferredException {
+		t.Fatal("upload is not marked as failed")
+	}
+}
+
+func TestAdditionalHeaders(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2000), server.Client(), 1000, nil)
+	headers := map[string]string{"Authorization": "Bearer token", "X-Tenant": "acme"}
+	u.SetAdditionalHeaders(headers)
+	// later changes to the map don't leak into the upload
+	headers["X-Tenant"] = "other"
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(requests))
+	}
+	for i, request := range requests {
+		if got := request.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("chunk %d Authorization %q", i, got)
+		}
+		if got := request.Header.Get("X-Tenant"); got != "acme" {
+			t.Errorf("chunk %d X-Tenant %q", i, got)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		status UploadStatus
+		bytes  int64
+		parts  uint64
+	}{
+		{"zero size", UploadStatus{}, 0, 0},
+		{"in progress", UploadStatus{Size: 10, SizeTransferred: 4, Parts: 3, PartsTransferred: 1}, 6, 2},
+		{"all transferred", UploadStatus{Size: 10, SizeTransferred: 10, Parts: 3, PartsTransferred: 3}, 0, 0},
+		{"over counted", UploadStatus{Size: 10, SizeTransferred: 12, Parts: 2, PartsTransferred: 3}, 0, 0},
+	} {
+		if got := test.status.BytesRemaining(); got != test.bytes {
+			t.Errorf("%s: %d bytes remaining, want %d", test.name, got, test.bytes)
+		}
+		if got := test.status.PartsRemaining(); got != test.parts {
+			t.Errorf("%s: %d parts remaining, want %d", test.name, got, test.parts)
+		}
+	}
+
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if u.Status.BytesRemaining() != 0 || u.Status.PartsRemaining() != 0 {
+		t.Fatalf("%d bytes and %d parts remaining after the upload", u.Status.BytesRemaining(), u.Status.PartsRemaining())
+	}
+}
+
+func TestTransferredSizeFromHeader(t *testing.T) {
+	// the body can't be parsed, the Range header has the offset
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		end := (n + 1) * 1000
+		if end > 2500 {
+			end = 2500
+		}
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", end-1))
+		w.Write([]byte("accepted"))
+	})
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetTransferredSizeFromHeader(true)
+	var transferred []int64
+	u.SetProgressHandler(func(status UploadStatus) {
+		transferred = append(transferred, status.SizeTransferred)
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1000, 2000, 2500}; !reflect.DeepEqual(transferred, want) {
+		t.Fatalf("transferred %v, want %v", transferred, want)
+	}
+}
+
+func TestTransferredSizeCalculator(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetTransferredSizeCalculator(func(body string, partSize int, status UploadStatus) (int64, error) {
+		return 1, nil
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if u.Status.SizeTransferred != 3 {
+		t.Fatalf("transferred %d bytes, want 1 per part", u.Status.SizeTransferred)
+	}
+}
+
+func TestContentTypeFunc(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetContentTypeFunc(func(index uint64) string {
+		if index == 0 {
+			return "application/json"
+		}
+		// the default applies
+		return ""
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"application/json", "application/octet-stream", "application/octet-stream"}
+	if got := server.Headers("Content-Type"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("content types %q, want %q", got, want)
+	}
+}
+
+func TestLastResponse(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 2 {
+			w.Header().Set("Location", "/objects/1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("2000-2499/2500"))
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	status := u.Status
+	if status.LastStatusCode != http.StatusCreated || status.LastResponseHeader.Get("Location") != "/objects/1" ||
+		status.LastResponseBody != "2000-2499/2500" {
+		t.Fatalf("last response %d %v %q", status.LastStatusCode, status.LastResponseHeader, status.LastResponseBody)
+	}
+}
+
+func TestPercent(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		status    UploadStatus
+		percent   float64
+		remaining int64
+	}{
+		{"zero size", UploadStatus{}, 0, 0},
+		{"zero size done", UploadStatus{IsDone: true}, 100, 0},
+		{"zero size failed", UploadStatus{IsDone: true, TransferredException: true}, 0, 0},
+		{"in progress", UploadStatus{Size: 4, SizeTransferred: 1}, 25, 3},
+		{"over counted", UploadStatus{Size: 4, SizeTransferred: 5}, 100, 0},
+	} {
+		if got := test.status.Percent(); got != test.percent {
+			t.Errorf("%s: %v%%, want %v%%", test.name, got, test.percent)
+		}
+		if got := test.status.Remaining(); got != test.remaining {
+			t.Errorf("%s: %d bytes remaining, want %d", test.name, got, test.remaining)
+		}
+	}
+}
+
+func TestSnapshotWhileUploading(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 50000), server.Client(), 1000, nil)
+	u.SetConcurrency(4)
+
+	// run with -race: the poller reads the status the upload writes
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for !u.Snapshot().IsDone {
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	<-polled
+	if status := u.Snapshot(); status.PartsTransferred != 50 || status.SizeTransferred != 50000 {
+		t.Fatalf("status %+v", status)
+	}
+}
+
+func TestContentTypeOnce(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		configure func(u *UploadData)
+		want      string
+	}{
+		{"option", func(u *UploadData) { u.SetContentType("image/jpeg") }, "image/jpeg"},
+		{"additional header", func(u *UploadData) {
+			u.SetAdditionalHeaders(map[string]string{"content-type": "image/png"})
+		}, "image/png"},
+	} {
+		server := newTestServer(t, nil)
+		u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+		test.configure(u)
+		if err := u.Init(); err != nil {
+			t.Fatal(err)
+		}
+		for _, request := range server.Requests() {
+			if got := request.Header.Values("Content-Type"); len(got) != 1 || got[0] != test.want {
+				t.Errorf("%s: Content-Type %q, want %q once", test.name, got, test.want)
+			}
+		}
+	}
+}
+
+func TestContentDispositionFileName(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := NewUploaderFromReader("PUT", server.URL, bytes.NewReader(testContent(10)), 10, server.Client(), 1000, nil)
+	u.SetFileName("data.bin")
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	path := testFile(t, 10)
+	if err := New("PUT", server.URL, path, server.Client(), 1000, nil).Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{`attachment; filename="data.bin"`, `attachment; filename="` + filepath.Base(path) + `"`}
+	if got := server.Headers("Content-Disposition"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Content-Disposition %q, want %q", got, want)
+	}
+}
+
+func TestRequestMutator(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetRetryBackoff(nil)
+	calls := 0
+	u.SetRequestMutator(func(request *http.Request) error {
+		calls++
+		if calls == 2 {
+			return errors.New("no token")
+		}
+		body, err := request.GetBody()
+		if err != nil {
+			return err
+		}
+		signed, err := ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("X-Signature", fmt.Sprint(len(signed)))
+		return nil
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed attempt isn't sent and is retried
+	if calls != 4 {
+		t.Fatalf("mutator called %d times, want 4", calls)
+	}
+	if got, want := server.Headers("X-Signature"), []string{"1000", "1000", "500"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("signatures %q, want %q", got, want)
+	}
+}
+
+func TestSuccessCodes(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n < 2 {
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetSuccessCodes(http.StatusOK, http.StatusCreated, http.StatusPermanentRedirect)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every 308 advances to the next chunk
+	want := []string{"bytes 0-999/2500", "bytes 1000-1999/2500", "bytes 2000-2499/2500"}
+	if got := server.Headers("Content-Range"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	server := newTestServer(t, nil)
+	logger := &memoryLogger{}
+	u := New("PUT", server.URL, testFile(t, 100), server.Client(), 1000, nil)
+	u.SetLogger(logger)
+	// before any upload, after Init closed the file itself, and once more
+	u.Close()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	u.Close()
+	u.Close()
+
+	for _, line := range logger.lines {
+		if strings.HasPrefix(line, "ERROR") {
+			t.Fatalf("closing logged %q", line)
+		}
+	}
+}
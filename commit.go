@@ -0,0 +1,141 @@
+package uploadbig
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// CommitOrder tells in which order uploaded parts are committed
+type CommitOrder int
+
+const (
+	// CommitOrderAny commits every part as soon as it has been uploaded
+	CommitOrderAny CommitOrder = iota
+	// CommitOrderStrict commits parts in offset order, holding back a part
+	// until all the parts before it have been committed
+	CommitOrderStrict
+)
+
+// SetCommit makes every uploaded part be followed by a commit request that
+// advances the durable offset of the upload, for stores that stage parts
+// and append them on commit. The request carries the Session-ID header and
+// the end of the committed part in Upload-Offset. A commit failing every
+// attempt allowed by SetMaxRetries fails the part.
+func (c *UploadData) SetCommit(request FlushRequest, order CommitOrder) {
+	c.commitRequest = &request
+	c.commitOrder = order
+}
+
+// commitSequencer serializes commits and, in strict order, releases them
+// by part index
+type commitSequencer struct {
+	mutex   sync.Mutex
+	order   CommitOrder
+	next    uint64
+	pending map[uint64]ChunkSpec
+	skipped map[uint64]bool
+	commit  func(chunk ChunkSpec) error
+}
+
+func newCommitSequencer(order CommitOrder, first uint64, commit func(chunk ChunkSpec) error) *commitSequencer {
+	return &commitSequencer{
+		order:   order,
+		next:    first,
+		pending: map[uint64]ChunkSpec{},
+		skipped: map[uint64]bool{},
+		commit:  commit,
+	}
+}
+
+// done is called when a part has been uploaded. It commits the part and,
+// in strict order, the held back parts following it.
+func (s *commitSequencer) done(chunk ChunkSpec) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.order == CommitOrderAny {
+		return s.commit(chunk)
+	}
+
+	s.pending[chunk.Index] = chunk
+	return s.release()
+}
+
+// skip is called for a part committed before, which is not committed again
+// but releases, in strict order, the held back parts following it
+func (s *commitSequencer) skip(index uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.order == CommitOrderAny {
+		return nil
+	}
+	s.skipped[index] = true
+	return s.release()
+}
+
+// release commits the parts following the committed ones in index order
+func (s *commitSequencer) release() error {
+	for {
+		if s.skipped[s.next] {
+			delete(s.skipped, s.next)
+			s.next++
+			continue
+		}
+		next, ok := s.pending[s.next]
+		if !ok {
+			return nil
+		}
+		err := s.commit(next)
+		if err != nil {
+			return err
+		}
+		delete(s.pending, s.next)
+		s.next++
+	}
+}
+
+// checkCommitted fails the upload when parts are still held back at its end
+func (c *UploadData) checkCommitted() error {
+	if c.commits == nil {
+		return nil
+	}
+	c.commits.mutex.Lock()
+	defer c.commits.mutex.Unlock()
+	if len(c.commits.pending) > 0 {
+		return fmt.Errorf("%d parts are not committed, part %d is missing", len(c.commits.pending), c.commits.next)
+	}
+	return nil
+}
+
+func (c *UploadData) startCommits() {
+	c.commits = nil
+	if c.commitRequest != nil {
+		c.commits = newCommitSequencer(c.commitOrder, 0, c.commitPart)
+	}
+}
+
+func (c *UploadData) commitPart(chunk ChunkSpec) error {
+	request := *c.commitRequest
+	request.Headers = make(map[string]string, len(c.commitRequest.Headers)+1)
+	for name, value := range c.commitRequest.Headers {
+		request.Headers[name] = value
+	}
+	request.Headers["Upload-Offset"] = strconv.FormatInt(chunk.Offset+int64(chunk.Length), 10)
+	request.Headers = c.sharedHeaders(request.Headers)
+
+	var err error
+	for errorCount := 0; errorCount < c.maxAttempts(); errorCount++ {
+		err = flushRequest(c.ctx, c.client, c.id, request, nil)
+		if err == nil {
+			c.debugf("Commit part %d", chunk.Index)
+			return nil
+		}
+		c.errorf("%v", err)
+		if !c.waitRetry(errorCount+1, 0) {
+			break
+		}
+	}
+	return err
+}
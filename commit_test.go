@@ -0,0 +1,66 @@
+package uploadbig
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStrictCommitOrder(t *testing.T) {
+	// later parts complete first
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodPut {
+			time.Sleep(time.Duration(5-n%5) * 5 * time.Millisecond)
+		}
+	})
+	u := New("PUT", server.URL+"/data", testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetConcurrency(3)
+	u.SetCommit(FlushRequest{Method: "POST", URL: server.URL + "/commit"}, CommitOrderStrict)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var offsets []string
+	for _, request := range server.Requests() {
+		if request.Method == http.MethodPost {
+			offsets = append(offsets, request.Header.Get("Upload-Offset"))
+		}
+	}
+	want := []string{"1000", "2000", "3000", "4000", "5000"}
+	if !reflect.DeepEqual(offsets, want) {
+		t.Fatalf("committed offsets %q, want %q", offsets, want)
+	}
+}
+
+func TestCommitSequencerHoldsBackParts(t *testing.T) {
+	var committed []uint64
+	sequencer := newCommitSequencer(CommitOrderStrict, 0, func(chunk ChunkSpec) error {
+		committed = append(committed, chunk.Index)
+		return nil
+	})
+	for _, i := range []uint64{2, 0, 3} {
+		if err := sequencer.done(ChunkSpec{Index: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []uint64{0}; !reflect.DeepEqual(committed, want) {
+		t.Fatalf("committed %v, want %v", committed, want)
+	}
+
+	// the upload must not finish with parts 2 and 3 held back
+	u := New("PUT", "", "", nil, 1000, nil)
+	u.commits = sequencer
+	if err := u.checkCommitted(); err == nil {
+		t.Fatal("held back parts were not reported")
+	}
+	if err := sequencer.done(ChunkSpec{Index: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.checkCommitted(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint64{0, 1, 2, 3}; !reflect.DeepEqual(committed, want) {
+		t.Fatalf("committed %v, want %v", committed, want)
+	}
+}
@@ -0,0 +1,71 @@
+package uploadbig
+
+import (
+	"bytes"
+	"compress/gzip"
+)
+
+// Codec compresses the chunks sent with its Content-Encoding token. zstd or
+// brotli can be used by implementing Codec with the library of choice.
+type Codec interface {
+	Compress(data []byte) ([]byte, error)
+	ContentEncoding() string
+}
+
+// GzipCodec is the codec used by SetCompression
+var GzipCodec Codec = gzipCodec{}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	_, err := writer.Write(data)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gzipCodec) ContentEncoding() string {
+	return "gzip"
+}
+
+// SetCompression makes chunks be sent gzip compressed with
+// "Content-Encoding: gzip". Content ranges still refer to the file bytes.
+func (c *UploadData) SetCompression(enabled bool) {
+	c.compression = enabled
+}
+
+// SetCompressionCodec makes chunks be sent compressed with codec and its
+// Content-Encoding instead of gzip. nil sends them uncompressed.
+func (c *UploadData) SetCompressionCodec(codec Codec) {
+	c.codec = codec
+	c.compression = codec != nil
+}
+
+// SetCompressionMinSize leaves chunks shorter than size uncompressed, as
+// compressing them costs more than it saves
+func (c *UploadData) SetCompressionMinSize(size int) {
+	c.compressionMinSize = size
+}
+
+// encodeChunk returns the body to send for part and its Content-Encoding
+func (c *UploadData) encodeChunk(part []byte) ([]byte, string, error) {
+	if !c.compression || len(part) < c.compressionMinSize {
+		return part, "", nil
+	}
+
+	codec := c.codec
+	if codec == nil {
+		codec = GzipCodec
+	}
+	body, err := codec.Compress(part)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, codec.ContentEncoding(), nil
+}
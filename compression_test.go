@@ -0,0 +1,90 @@
+package uploadbig
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+// decodeBody returns the body of request without its Content-Encoding
+func decodeBody(t *testing.T, request testRequest) []byte {
+	t.Helper()
+	if request.Header.Get("Content-Encoding") != "gzip" {
+		return request.Body
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(request.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestCompressionMinSize(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2300), server.Client(), 1000, nil)
+	u.SetCompression(true)
+	u.SetCompressionMinSize(500)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("%d requests, want 3", len(requests))
+	}
+	content := testContent(2300)
+	for i, request := range requests {
+		// only the last chunk of 300 bytes is below the threshold
+		want := "gzip"
+		if i == 2 {
+			want = ""
+		}
+		if got := request.Header.Get("Content-Encoding"); got != want {
+			t.Errorf("chunk %d encoding %q, want %q", i, got, want)
+		}
+		if !bytes.Equal(decodeBody(t, request), testChunk(content, i, 1000)) {
+			t.Errorf("chunk %d content differs", i)
+		}
+	}
+}
+
+// xorCodec is a codec of its own, decoded by applying it again
+type xorCodec struct{}
+
+func (xorCodec) Compress(data []byte) ([]byte, error) {
+	encoded := make([]byte, len(data))
+	for i, b := range data {
+		encoded[i] = b ^ 0x5a
+	}
+	return encoded, nil
+}
+
+func (xorCodec) ContentEncoding() string {
+	return "x-xor"
+}
+
+func TestCompressionCodec(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetCompressionCodec(xorCodec{})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []byte
+	for _, request := range server.Requests() {
+		if got := request.Header.Get("Content-Encoding"); got != "x-xor" {
+			t.Fatalf("Content-Encoding %q, want x-xor", got)
+		}
+		decoded, _ := xorCodec{}.Compress(request.Body)
+		received = append(received, decoded...)
+	}
+	if !bytes.Equal(received, testContent(2500)) {
+		t.Fatal("the decoded content differs")
+	}
+}
@@ -0,0 +1,80 @@
+package uploadbig
+
+// SetConcurrency sends up to n chunks of a file upload at the same time,
+// for servers accepting chunks out of order; 1, the default, sends one at
+// a time. Reader-backed uploads, sinks and Append ignore it. With it a 416
+// fails the part, SetOffsetReprobe and SetOffsetCompletion have no effect
+// and a Recorder is called concurrently.
+func (c *UploadData) SetConcurrency(n int) {
+	c.concurrency = n
+}
+
+func (c *UploadData) concurrent() bool {
+	return c.concurrency > 1 && !c.tus && !c.fromReader && c.readerWrapper == nil && c.sink == nil && c.partCommitted == nil
+}
+
+// uploadConcurrently is uploadFile sending up to c.concurrency chunks at once
+func (c *UploadData) uploadConcurrently(start uint64) {
+	results := make(chan *chunkUpload)
+	inFlight := 0
+	i := start
+	var stopped error
+
+	for !c.Status.IsDone {
+		for inFlight < c.concurrency && i < c.Status.Parts && stopped == nil && !c.Status.IsDone {
+			if c.ctx.Err() != nil {
+				stopped = c.ctx.Err()
+				break
+			}
+			if c.sessionExpired() {
+				stopped = ErrSessionExpired
+				break
+			}
+			if !c.scheduleOpen() {
+				// the chunks in flight are completed before pausing
+				if inFlight > 0 {
+					break
+				}
+				stopped = c.waitSchedule()
+				if stopped != nil {
+					break
+				}
+			}
+			upload := c.prepareChunk(i)
+			i++
+			if upload == nil {
+				continue
+			}
+			inFlight++
+			go func() {
+				c.sendAttempts(upload)
+				results <- upload
+			}()
+		}
+		if inFlight == 0 {
+			break
+		}
+
+		upload := <-results
+		inFlight--
+		committed := c.Status.PartsTransferred
+		c.completeChunk(upload)
+		if !c.Status.IsDone && c.Status.PartsTransferred > committed && c.needFlush(c.Status.PartsTransferred) {
+			c.checkError(c.flush())
+		}
+	}
+
+	for ; inFlight > 0; inFlight-- {
+		c.releaseSpill(<-results)
+	}
+	switch {
+	case c.Status.IsDone:
+	case c.ctx.Err() != nil:
+		c.errorf("Upload %s stopped: %v", c.id, c.ctx.Err())
+		c.uploadDone(true)
+	case stopped != nil:
+		c.checkError(stopped)
+	default:
+		c.uploadChunk(c.Status.Parts)
+	}
+}
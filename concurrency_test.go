@@ -0,0 +1,58 @@
+package uploadbig
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPartsTransferredConcurrent(t *testing.T) {
+	// later parts finish first
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		time.Sleep(time.Duration(10-n%10) * 3 * time.Millisecond)
+	})
+	u := New("PUT", server.URL, testFile(t, 10000), server.Client(), 1000, nil)
+	u.SetConcurrency(4)
+	var counts []uint64
+	u.SetProgressHandler(func(status UploadStatus) {
+		counts = append(counts, status.PartsTransferred)
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := u.Snapshot(); status.PartsTransferred != 10 {
+		t.Fatalf("parts transferred %d, want 10", status.PartsTransferred)
+	}
+	for i, count := range counts {
+		if count != uint64(i+1) {
+			t.Fatalf("progress counts %v, want one more part each time", counts)
+		}
+	}
+}
+
+func TestDiagnoseConcurrent(t *testing.T) {
+	// later parts finish first and part 3 is rejected
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		time.Sleep(time.Duration(10-n%10) * 3 * time.Millisecond)
+		if r.Header.Get("Content-Range") == "bytes 3000-3999/10000" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 10000), server.Client(), 1000, nil)
+	u.SetConcurrency(4)
+	u.SetMaxRetries(0)
+	results, err := u.Diagnose()
+	if err == nil {
+		t.Fatal("Diagnose returned no error for a rejected part")
+	}
+
+	if len(results) != 10 {
+		t.Fatalf("%d results, want 10", len(results))
+	}
+	for i, result := range results {
+		if result.Index != uint64(i) || result.OK != (i != 3) {
+			t.Errorf("result %d: %+v", i, result)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// SetContentHashID makes the session ID the hex digest of the content
+// instead of a random value, so uploading identical content again, from
+// any machine, addresses the same server-side upload and can resume it.
+// newHash creates the hash, nil means SHA-256. The content is read once
+// before the upload; a reader source must be seekable.
+func (c *UploadData) SetContentHashID(newHash func() hash.Hash) {
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	c.contentHash = newHash
+}
+
+func (c *UploadData) contentHashID() (string, error) {
+	digest := c.contentHash()
+	if !c.fromReader {
+		file, err := os.Open(c.filePath)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		_, err = io.Copy(digest, file)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(digest.Sum(nil)), nil
+	}
+
+	seeker, ok := c.reader.(io.Seeker)
+	if !ok {
+		return "", fmt.Errorf("content hash needs a seekable reader, %T isn't", c.reader)
+	}
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.CopyN(digest, c.reader, c.size)
+	if err != nil {
+		return "", err
+	}
+	_, err = seeker.Seek(current, io.SeekStart)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
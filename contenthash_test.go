@@ -0,0 +1,61 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestContentHashIDResumes(t *testing.T) {
+	// the server keeps the offset of every session and fails the second
+	// part of the first attempt
+	var mutex sync.Mutex
+	offsets := map[string]int64{}
+	failing := true
+	var accepted []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		id := r.Header.Get("Session-ID")
+		if r.Method == http.MethodHead {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offsets[id], 10))
+			return
+		}
+		if failing && offsets[id] == 1000 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		accepted = append(accepted, r.Header.Get("Content-Range"))
+		offsets[id] += r.ContentLength
+	})
+	path := testFile(t, 2500)
+
+	first := New("PUT", server.URL, path, server.Client(), 1000, nil)
+	first.SetContentHashID(nil)
+	first.SetMaxRetries(0)
+	if err := first.Init(); err == nil {
+		t.Fatal("the first upload succeeded")
+	}
+
+	mutex.Lock()
+	failing = false
+	mutex.Unlock()
+	second := New("PUT", server.URL, path, server.Client(), 1000, nil)
+	second.SetContentHashID(nil)
+	if err := second.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(testContent(2500))
+	if want := hex.EncodeToString(sum[:]); first.id != want || second.id != want {
+		t.Fatalf("session IDs %s and %s, want %s", first.id, second.id, want)
+	}
+	want := []string{"bytes 0-999/2500", "bytes 1000-1999/2500", "bytes 2000-2499/2500"}
+	if !reflect.DeepEqual(accepted, want) {
+		t.Fatalf("accepted ranges %q, want %q", accepted, want)
+	}
+}
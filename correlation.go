@@ -0,0 +1,21 @@
+package uploadbig
+
+// CorrelationHeader is the header carrying the correlation ID
+const CorrelationHeader = "X-Correlation-Id"
+
+// SetCorrelationID sends value in the X-Correlation-Id header of every
+// request of the upload: chunks, flushes, commits, finalize and probes. An
+// empty value generates one. Unlike the idempotency key it is the same for
+// the whole upload.
+func (c *UploadData) SetCorrelationID(value string) {
+	if value == "" {
+		value = generateSessionID()
+	}
+	c.correlationID = value
+}
+
+// CorrelationID returns the correlation ID sent with the requests, empty
+// when SetCorrelationID wasn't called
+func (c *UploadData) CorrelationID() string {
+	return c.correlationID
+}
@@ -0,0 +1,44 @@
+package uploadbig
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestCorrelationIDOnEveryPhase(t *testing.T) {
+	// a tus server, whose uploads start with a create request
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/files":
+			w.Header().Set("Location", "/files/1")
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPatch:
+			offset, _ := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset+r.ContentLength, 10))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	u := New("PATCH", server.URL+"/files", testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetTus(true)
+	u.SetCorrelationID("")
+	u.SetFinalize(FlushRequest{Method: "POST", URL: server.URL + "/complete"}, JSONManifestEncoder)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	id := u.CorrelationID()
+	if id == "" {
+		t.Fatal("no correlation ID was generated")
+	}
+	var phases []string
+	for _, request := range server.Requests() {
+		phases = append(phases, request.Method+" "+request.URL)
+		if got := request.Header.Get(CorrelationHeader); got != id {
+			t.Errorf("%s %s has correlation ID %q, want %q", request.Method, request.URL, got, id)
+		}
+	}
+	if len(phases) != 5 || phases[0] != "POST /files" || phases[4] != "POST /complete" {
+		t.Fatalf("requests %q, want create, 3 chunks and finalize", phases)
+	}
+}
@@ -0,0 +1,37 @@
+package uploadbig
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SetCRC32C makes the upload compute the CRC32C (Castagnoli) of the source
+// as the chunks are read and send it with the last chunk, which finalizes
+// the upload, as "crc32c=<base64>" in headerName. An empty headerName
+// defaults to x-goog-hash, as expected by Google Cloud Storage.
+func (c *UploadData) SetCRC32C(headerName string) {
+	if headerName == "" {
+		headerName = "x-goog-hash"
+	}
+	c.crc32cHeader = headerName
+}
+
+// CRC32C returns the checksum of the bytes read by the upload
+func (c *UploadData) CRC32C() uint32 {
+	return c.crc32c
+}
+
+func (c *UploadData) updateCRC32C(part []byte) {
+	if c.crc32cHeader != "" {
+		c.crc32c = crc32.Update(c.crc32c, castagnoliTable, part)
+	}
+}
+
+func encodeCRC32C(checksum uint32) string {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, checksum)
+	return "crc32c=" + base64.StdEncoding.EncodeToString(b)
+}
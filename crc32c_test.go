@@ -0,0 +1,29 @@
+package uploadbig
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCRC32C(t *testing.T) {
+	server := newTestServer(t, nil)
+	// the CRC-32C check value of "123456789" is 0xE3069283
+	content := []byte("123456789")
+	u := NewUploaderFromReader("PUT", server.URL, bytes.NewReader(content), int64(len(content)), server.Client(), 4, nil)
+	u.SetCRC32C("")
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.CRC32C(); got != 0xE3069283 {
+		t.Fatalf("CRC32C %#x, want 0xe3069283", got)
+	}
+
+	hashes := server.Headers("x-goog-hash")
+	if len(hashes) != 3 {
+		t.Fatalf("%d chunks, want 3", len(hashes))
+	}
+	// only the last chunk finalizes the upload
+	if hashes[0] != "" || hashes[1] != "" || hashes[2] != "crc32c=4waSgw==" {
+		t.Fatalf("checksum headers %q", hashes)
+	}
+}
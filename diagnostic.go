@@ -0,0 +1,58 @@
+package uploadbig
+
+import "sort"
+
+// PartResult is the outcome of a single part in diagnostic mode
+type PartResult struct {
+	Index        uint64
+	ContentRange string
+	OK           bool
+	StatusCode   int
+	Err          error
+}
+
+// Diagnose runs the upload like Init but never stops on a failed part: every
+// part is attempted and its outcome recorded. It returns the results of all
+// parts in order along with the error returned by Init.
+func (c *UploadData) Diagnose() ([]PartResult, error) {
+	c.diagnostic = true
+	c.diagnosticFailed = false
+	c.partResults = nil
+	defer func() {
+		c.diagnostic = false
+	}()
+
+	err := c.Init()
+	results := append([]PartResult(nil), c.partResults...)
+	// concurrent parts are recorded as they complete
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Index < results[j].Index
+	})
+	return results, err
+}
+
+func (c *UploadData) recordPart(index uint64, contentRange string, response chunkResponse, err error) {
+	if !c.diagnostic {
+		return
+	}
+	c.partResults = append(c.partResults, PartResult{
+		Index:        index,
+		ContentRange: contentRange,
+		OK:           err == nil,
+		StatusCode:   response.statusCode,
+		Err:          err,
+	})
+}
+
+// partFailed records the failure in diagnostic mode or stops the upload
+func (c *UploadData) partFailed(index uint64, contentRange string, response chunkResponse, err error) {
+	if c.failure == nil {
+		c.failure = err
+	}
+	if c.diagnostic {
+		c.recordPart(index, contentRange, response, err)
+		c.diagnosticFailed = true
+		return
+	}
+	c.uploadDone(true)
+}
@@ -0,0 +1,35 @@
+package uploadbig
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDiagnoseRecordsEveryPart(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Header.Get("Content-Range") == "bytes 1000-1999/3000" {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	u.SetMaxRetries(0)
+	results, err := u.Diagnose()
+	if err == nil {
+		t.Fatal("Diagnose returned no error for a rejected part")
+	}
+	if len(results) != 3 {
+		t.Fatalf("%d results, want 3", len(results))
+	}
+	for i, result := range results {
+		wantOK := i != 1
+		if result.Index != uint64(i) || result.OK != wantOK {
+			t.Errorf("result %d: %+v", i, result)
+		}
+	}
+	if results[1].StatusCode != http.StatusRequestedRangeNotSatisfiable || results[1].ContentRange != "bytes 1000-1999/3000" {
+		t.Errorf("rejected part: %+v", results[1])
+	}
+	if !u.Status.TransferredException {
+		t.Error("upload is not marked as failed")
+	}
+}
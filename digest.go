@@ -0,0 +1,66 @@
+package uploadbig
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+var digestAlgorithms = map[string]func() hash.Hash{
+	"md5":     md5.New,
+	"sha":     sha1.New,
+	"sha-256": sha256.New,
+	"sha-512": sha512.New,
+}
+
+// SetDigest adds an RFC 3230 Digest header to every chunk, e.g.
+// "Digest: sha-256=<base64>". Supported algorithms are md5, sha, sha-256
+// and sha-512; several of them are joined by commas in the given order.
+func (c *UploadData) SetDigest(algorithms ...string) error {
+	names := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		names[i] = strings.ToLower(algorithm)
+		if _, ok := digestAlgorithms[names[i]]; !ok {
+			return fmt.Errorf("unsupported digest algorithm %s", algorithm)
+		}
+	}
+	c.digestAlgorithms = names
+	return nil
+}
+
+func digestHeader(algorithms []string, part []byte) string {
+	values := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		h := digestAlgorithms[algorithm]()
+		h.Write(part)
+		values[i] = algorithm + "=" + base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+	return strings.Join(values, ",")
+}
+
+// ChecksumFunc returns the checksum header value of a chunk body
+type ChecksumFunc func(body []byte) string
+
+// SetChecksum adds the checksum of every chunk body, as sent, in
+// headerName so the server can validate it. An empty headerName defaults
+// to Content-MD5 and a nil checksum to the base64 MD5 it expects.
+func (c *UploadData) SetChecksum(headerName string, checksum ChecksumFunc) {
+	if headerName == "" {
+		headerName = "Content-MD5"
+	}
+	if checksum == nil {
+		checksum = contentMD5
+	}
+	c.checksumHeader = headerName
+	c.checksumFunc = checksum
+}
+
+func contentMD5(body []byte) string {
+	sum := md5.Sum(body)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
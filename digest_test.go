@@ -0,0 +1,81 @@
+package uploadbig
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestDigestHeader(t *testing.T) {
+	// the RFC 3230 examples use the base64 of the raw digest
+	want := "sha-256=LPJNul+wow4m6DsqxbninhsWHlwfp0JecwQzYpOLmCQ=,md5=XUFAKrxLKna5cZ2REBfFkg=="
+	if got := digestHeader([]string{"sha-256", "md5"}, []byte("hello")); got != want {
+		t.Fatalf("digest %q, want %q", got, want)
+	}
+
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 1500), server.Client(), 1000, nil)
+	if err := u.SetDigest("SHA-256"); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SetDigest("crc32"); err == nil {
+		t.Fatal("unsupported algorithm accepted")
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	content := testContent(1500)
+	digests := server.Headers("Digest")
+	if len(digests) != 2 {
+		t.Fatalf("%d chunks, want 2", len(digests))
+	}
+	for i, got := range digests {
+		sum := sha256.Sum256(testChunk(content, i, 1000))
+		if want := "sha-256=" + base64.StdEncoding.EncodeToString(sum[:]); got != want {
+			t.Errorf("chunk %d digest %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestChecksumHeader(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetChecksum("", nil)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	content := testContent(2500)
+	checksums := server.Headers("Content-MD5")
+	if len(checksums) != 3 {
+		t.Fatalf("%d chunks, want 3", len(checksums))
+	}
+	for i, checksum := range checksums {
+		sum := md5.Sum(testChunk(content, i, 1000))
+		if want := base64.StdEncoding.EncodeToString(sum[:]); checksum != want {
+			t.Errorf("chunk %d: Content-MD5 %q, want %q", i, checksum, want)
+		}
+	}
+}
+
+func TestChecksumFunc(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 1500), server.Client(), 1000, nil)
+	u.SetChecksum("X-Checksum-SHA256", func(body []byte) string {
+		return hex.EncodeToString(sha256Of(body))
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, request := range server.Requests() {
+		if got, want := request.Header.Get("X-Checksum-SHA256"), hex.EncodeToString(sha256Of(request.Body)); got != want {
+			t.Errorf("checksum %q, want %q", got, want)
+		}
+		if request.Header.Get("Content-MD5") != "" {
+			t.Error("Content-MD5 sent with another checksum header")
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package uploadbig
+
+import "time"
+
+// Event is an upload lifecycle notification received from Events. It is one
+// of ChunkStarted, ChunkCompleted, ChunkFailed, Progress, Done or Aborted.
+type Event interface {
+	isEvent()
+}
+
+// ChunkStarted is sent before the first attempt to send a chunk
+type ChunkStarted struct {
+	Index        uint64
+	ContentRange string
+}
+
+// ChunkCompleted is sent when the server has accepted a chunk
+type ChunkCompleted struct {
+	Index uint64
+	Bytes int64
+}
+
+// ChunkFailed is sent for every failed attempt to send a chunk
+type ChunkFailed struct {
+	Index   uint64
+	Attempt int
+	Err     error
+}
+
+// Progress is sent after every completed chunk, or at most once per the
+// interval set by SetProgressInterval
+type Progress struct {
+	Status UploadStatus
+}
+
+// Done is sent when the upload has finished successfully
+type Done struct {
+	Status UploadStatus
+}
+
+// Aborted is sent when the upload has been stopped by an error
+type Aborted struct {
+	Status UploadStatus
+}
+
+func (ChunkStarted) isEvent()   {}
+func (ChunkCompleted) isEvent() {}
+func (ChunkFailed) isEvent()    {}
+func (Progress) isEvent()       {}
+func (Done) isEvent()           {}
+func (Aborted) isEvent()        {}
+
+const eventsBufferSize = 64
+
+// Events returns the channel the upload lifecycle events are sent to. It must
+// be called before Init. Sends never block the upload: when the consumer
+// falls behind and the buffer of 64 events is full, new events are dropped.
+// The channel is closed after Done or Aborted.
+func (c *UploadData) Events() <-chan Event {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+	if c.events == nil {
+		c.events = make(chan Event, eventsBufferSize)
+	}
+	return c.events
+}
+
+func (c *UploadData) emit(event Event) {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+	if c.events == nil {
+		return
+	}
+	select {
+	case c.events <- event:
+	default:
+		c.debugf("Event %T dropped", event)
+	}
+}
+
+// ProgressFunc receives a copy of the status after a chunk has been
+// transferred
+type ProgressFunc func(status UploadStatus)
+
+// SetProgressHandler makes handler be called after every transferred chunk,
+// once the status has been updated. It runs synchronously on the goroutine
+// of the upload, which waits for it to return. The interval set by
+// SetProgressInterval applies to it as to the Progress event.
+func (c *UploadData) SetProgressHandler(handler ProgressFunc) {
+	c.progressHandler = handler
+}
+
+// SetProgressInterval sends Progress at most once per interval instead of
+// after every chunk. The Progress of the last chunk is always sent.
+func (c *UploadData) SetProgressInterval(interval time.Duration) {
+	c.progressInterval = interval
+}
+
+func (c *UploadData) emitProgress() {
+	c.renderProgress()
+	if c.progressInterval > 0 && c.Status.PartsTransferred < c.Status.Parts {
+		now := time.Now()
+		if !c.lastProgress.IsZero() && now.Sub(c.lastProgress) < c.progressInterval {
+			return
+		}
+		c.lastProgress = now
+	}
+	c.emit(Progress{Status: c.Status})
+	if c.progressHandler != nil {
+		c.progressHandler(c.Status)
+	}
+}
+
+func (c *UploadData) closeEvents() {
+	c.eventsMutex.Lock()
+	defer c.eventsMutex.Unlock()
+	if c.events != nil {
+		close(c.events)
+		c.events = nil
+	}
+}
@@ -0,0 +1,74 @@
+package uploadbig
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEventsSequence(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 2000), server.Client(), 1000, nil)
+	events := u.Events()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for event := range events {
+		got = append(got, fmt.Sprintf("%T", event))
+	}
+	want := []string{
+		"uploadbig.ChunkStarted", "uploadbig.ChunkCompleted", "uploadbig.Progress",
+		"uploadbig.ChunkStarted", "uploadbig.ChunkFailed", "uploadbig.ChunkCompleted", "uploadbig.Progress",
+		"uploadbig.Done",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("events %v, want %v", got, want)
+	}
+}
+
+func TestProgressHandler(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	var transferred []int64
+	u.SetProgressHandler(func(status UploadStatus) {
+		transferred = append(transferred, status.SizeTransferred)
+		// the handler gets a copy
+		status.Size = 0
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int64{1000, 2000, 2500}; !reflect.DeepEqual(transferred, want) {
+		t.Fatalf("notified at %v bytes, want %v", transferred, want)
+	}
+	if u.Status.Size != 2500 {
+		t.Fatalf("status size changed to %d", u.Status.Size)
+	}
+}
+
+func TestProgressInterval(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 50), server.Client(), 1, nil)
+	u.SetProgressInterval(time.Hour)
+	var notified []uint64
+	u.SetProgressHandler(func(status UploadStatus) {
+		notified = append(notified, status.PartsTransferred)
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first chunk starts the interval, the last one is always reported
+	if want := []uint64{1, 50}; !reflect.DeepEqual(notified, want) {
+		t.Fatalf("notified at %v parts, want %v", notified, want)
+	}
+}
@@ -0,0 +1,73 @@
+package uploadbig
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// FlushRequest describes the request that tells the server to durably
+// persist the chunks received so far
+type FlushRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+}
+
+// SetFlush makes uploadFile issue the flush request after every `every`
+// transferred chunks and once more after the last one. Zero disables flushing.
+func (c *UploadData) SetFlush(every int, request FlushRequest) {
+	c.flushEvery = every
+	c.flushRequest = request
+}
+
+func (c *UploadData) needFlush(partsTransferred uint64) bool {
+	if c.flushEvery <= 0 || partsTransferred == 0 {
+		return false
+	}
+	return partsTransferred%uint64(c.flushEvery) == 0 || partsTransferred == c.Status.Parts
+}
+
+func (c *UploadData) flush() error {
+	request := c.flushRequest
+	request.Headers = c.sharedHeaders(request.Headers)
+
+	var err error
+	for errorCount := 0; errorCount < c.maxAttempts(); errorCount++ {
+		err = flushRequest(c.ctx, c.client, c.id, request, nil)
+		if err == nil {
+			c.debugf("Flush after part %d", c.Status.PartsTransferred)
+			return nil
+		}
+		c.errorf("%v", err)
+		if !c.waitRetry(errorCount+1, 0) {
+			break
+		}
+	}
+	return err
+}
+
+func flushRequest(ctx context.Context, client *http.Client, sessionID string, flush FlushRequest, body []byte) error {
+	request, err := http.NewRequestWithContext(ctx, flush.Method, flush.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	setHeaders(request, flush.Headers)
+	request.Header.Set("Session-ID", sessionID)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s %s failed with HTTP code %d", flush.Method, flush.URL, response.StatusCode)
+	}
+	return nil
+}
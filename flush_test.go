@@ -0,0 +1,28 @@
+package uploadbig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlushIntervals(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL+"/data", testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetFlush(2, FlushRequest{Method: "POST", URL: server.URL + "/flush"})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, request := range server.Requests() {
+		got = append(got, request.Method+" "+request.URL)
+	}
+	want := []string{
+		"PUT /data", "PUT /data", "POST /flush",
+		"PUT /data", "PUT /data", "POST /flush",
+		"PUT /data", "POST /flush",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("requests %q, want %q", got, want)
+	}
+}
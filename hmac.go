@@ -0,0 +1,34 @@
+package uploadbig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// HMACMessageFunc builds the message signed for a chunk
+type HMACMessageFunc func(sessionID string, contentRange string, index uint64) string
+
+// DefaultHMACMessage signs the session ID, the content range and the part index concatenated
+func DefaultHMACMessage(sessionID string, contentRange string, index uint64) string {
+	return sessionID + contentRange + strconv.FormatUint(index, 10)
+}
+
+// SetHMAC adds the hex encoded HMAC-SHA256 of every chunk's message to the
+// headerName header. A nil message uses DefaultHMACMessage. The secret is
+// copied and never logged.
+func (c *UploadData) SetHMAC(secret []byte, headerName string, message HMACMessageFunc) {
+	if message == nil {
+		message = DefaultHMACMessage
+	}
+	c.hmacSecret = append([]byte(nil), secret...)
+	c.hmacHeader = headerName
+	c.hmacMessage = message
+}
+
+func (c *UploadData) signChunk(index uint64, contentRange string) string {
+	mac := hmac.New(sha256.New, c.hmacSecret)
+	mac.Write([]byte(c.hmacMessage(c.id, contentRange, index)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
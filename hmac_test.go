@@ -0,0 +1,30 @@
+package uploadbig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+)
+
+func TestHMACSignature(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2000), server.Client(), 1000, nil)
+	u.SetHMAC([]byte("secret"), "X-Signature", nil)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(requests))
+	}
+	for i, request := range requests {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(request.Header.Get("Session-ID") + request.Header.Get("Content-Range") + strconv.Itoa(i)))
+		if want := hex.EncodeToString(mac.Sum(nil)); request.Header.Get("X-Signature") != want {
+			t.Errorf("chunk %d signed %q, want %q", i, request.Header.Get("X-Signature"), want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package uploadbig
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+)
+
+// SetHost sends host in the Host header and as the TLS server name, while
+// the connection goes to the host of the URL. See configureTransport.
+func (c *UploadData) SetHost(host string) error {
+	serverName := host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		serverName = name
+	}
+	err := c.configureTransport(func(transport *http.Transport) {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.ServerName = serverName
+	})
+	if err != nil {
+		return err
+	}
+	c.host = host
+	return nil
+}
+
+// sharedHeaders adds the headers sent with every request of the upload to
+// headers, returning a copy when anything is added
+func (c *UploadData) sharedHeaders(headers map[string]string) map[string]string {
+	if c.host == "" && c.correlationID == "" && len(c.sseHeaders) == 0 && len(c.additionalHeaders) == 0 && !c.tus {
+		return headers
+	}
+
+	result := make(map[string]string, len(c.additionalHeaders)+len(headers)+3+len(c.sseHeaders))
+	for name, value := range c.additionalHeaders {
+		result[name] = value
+	}
+	for name, value := range headers {
+		result[name] = value
+	}
+	if c.host != "" {
+		result["Host"] = c.host
+	}
+	if c.correlationID != "" {
+		result[CorrelationHeader] = c.correlationID
+	}
+	for name, value := range c.sseHeaders {
+		result[name] = value
+	}
+	if c.tus {
+		result["Tus-Resumable"] = TusVersion
+	}
+	return result
+}
+
+// setHeaders sets headers on request. Host goes to request.Host, net/http
+// ignores it in the header map.
+func setHeaders(request *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			request.Host = value
+			continue
+		}
+		request.Header.Set(name, value)
+	}
+}
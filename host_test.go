@@ -0,0 +1,25 @@
+package uploadbig
+
+import "testing"
+
+func TestHostOverride(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	if err := u.SetHost("files.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	u.SetFlush(2, FlushRequest{Method: "POST", URL: server.URL})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 5 {
+		t.Fatalf("%d requests, want 3 chunks and 2 flushes", len(requests))
+	}
+	for _, request := range requests {
+		if request.Host != "files.example.com" {
+			t.Errorf("%s request Host %q", request.Method, request.Host)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package uploadbig
+
+import (
+	"strconv"
+)
+
+// IdempotencyMode tells when a new idempotency key is generated
+type IdempotencyMode int
+
+const (
+	// IdempotencyStablePerChunk sends the same key on every attempt of a chunk
+	IdempotencyStablePerChunk IdempotencyMode = iota
+	// IdempotencyFreshPerAttempt sends a new key on every attempt
+	IdempotencyFreshPerAttempt
+)
+
+// SetIdempotencyKey adds an idempotency key to every chunk request in the
+// headerName header. The stable key is derived from the session ID and the
+// part index, so the server can deduplicate retries; a fresh key is random.
+func (c *UploadData) SetIdempotencyKey(headerName string, mode IdempotencyMode) {
+	c.idempotencyHeader = headerName
+	c.idempotencyMode = mode
+}
+
+func (c *UploadData) attemptHeaders(headers map[string]string, index uint64) map[string]string {
+	if c.idempotencyHeader == "" {
+		return headers
+	}
+
+	result := make(map[string]string, len(headers)+1)
+	for name, value := range headers {
+		result[name] = value
+	}
+	if c.idempotencyMode == IdempotencyFreshPerAttempt {
+		result[c.idempotencyHeader] = generateSessionID()
+	} else {
+		result[c.idempotencyHeader] = c.id + "-" + strconv.FormatUint(index, 10)
+	}
+	return result
+}
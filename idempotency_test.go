@@ -0,0 +1,38 @@
+package uploadbig
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIdempotencyKeyModes(t *testing.T) {
+	for _, mode := range []IdempotencyMode{IdempotencyStablePerChunk, IdempotencyFreshPerAttempt} {
+		server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+			if n == 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+		u := New("PUT", server.URL, testFile(t, 2000), server.Client(), 1000, nil)
+		u.SetIdempotencyKey("Idempotency-Key", mode)
+		if err := u.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		// the first chunk is retried once, then the second one is sent
+		keys := server.Headers("Idempotency-Key")
+		if len(keys) != 3 {
+			t.Fatalf("mode %v: %d requests, want 3", mode, len(keys))
+		}
+		for _, key := range keys {
+			if key == "" {
+				t.Fatalf("mode %v: a request has no key", mode)
+			}
+		}
+		if stable := keys[0] == keys[1]; stable != (mode == IdempotencyStablePerChunk) {
+			t.Errorf("mode %v: retry keys %q and %q", mode, keys[0], keys[1])
+		}
+		if keys[1] == keys[2] {
+			t.Errorf("mode %v: two chunks share the key %q", mode, keys[1])
+		}
+	}
+}
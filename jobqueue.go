@@ -0,0 +1,114 @@
+package uploadbig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	jobFileSuffix   = ".job.json"
+	stateFileSuffix = ".state.json"
+)
+
+// Job is an upload waiting in a JobQueue
+type Job struct {
+	ID        string `json:"id"`
+	Method    string `json:"method"`
+	URL       string `json:"url"`
+	FilePath  string `json:"filePath"`
+	ChunkSize int    `json:"chunkSize"`
+}
+
+// JobQueue is a persistent queue of uploads kept in a directory. Every job
+// has a job file and, once started, a ResumeState file, so a worker
+// restarted in the middle of a job continues it from the last committed
+// part. It is meant for a single worker.
+type JobQueue struct {
+	dir    string
+	client *http.Client
+	logger *Logger
+}
+
+// NewJobQueue opens the queue in dir, creating the directory if needed.
+// client and logger are used by the uploads run by Run.
+func NewJobQueue(dir string, client *http.Client, logger *Logger) (*JobQueue, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &JobQueue{dir: dir, client: client, logger: logger}, nil
+}
+
+// Enqueue adds the job to the end of the queue and returns it with its ID set
+func (q *JobQueue) Enqueue(job Job) (Job, error) {
+	job.ID = fmt.Sprintf("%020d-%s", time.Now().UnixNano(), generateSessionID())
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return job, err
+	}
+	tmp := q.jobPath(job.ID) + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0644)
+	if err != nil {
+		return job, err
+	}
+	return job, os.Rename(tmp, q.jobPath(job.ID))
+}
+
+// Next returns the oldest job that hasn't been completed. ok is false when
+// the queue is empty.
+func (q *JobQueue) Next() (job Job, ok bool, err error) {
+	files, err := ioutil.ReadDir(q.dir)
+	if err != nil {
+		return job, false, err
+	}
+
+	var names []string
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), jobFileSuffix) {
+			names = append(names, file.Name())
+		}
+	}
+	if len(names) == 0 {
+		return job, false, nil
+	}
+	sort.Strings(names)
+
+	data, err := ioutil.ReadFile(filepath.Join(q.dir, names[0]))
+	if err != nil {
+		return job, false, err
+	}
+	err = json.Unmarshal(data, &job)
+	return job, err == nil, err
+}
+
+// Run uploads the job, continuing from its saved state if it was started before
+func (q *JobQueue) Run(job Job) error {
+	uploader := New(job.Method, job.URL, job.FilePath, q.client, job.ChunkSize, q.logger)
+	uploader.SetStatePath(q.statePath(job.ID))
+	return uploader.Append()
+}
+
+// Complete removes the job and its state from the queue
+func (q *JobQueue) Complete(id string) error {
+	err := os.Remove(q.statePath(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Remove(q.jobPath(id))
+}
+
+func (q *JobQueue) jobPath(id string) string {
+	return filepath.Join(q.dir, id+jobFileSuffix)
+}
+
+func (q *JobQueue) statePath(id string) string {
+	return filepath.Join(q.dir, id+stateFileSuffix)
+}
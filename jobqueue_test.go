@@ -0,0 +1,72 @@
+package uploadbig
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJobQueueContinuesAfterRestart(t *testing.T) {
+	var failing int32 = 1
+	var mutex sync.Mutex
+	var accepted []string
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if atomic.LoadInt32(&failing) == 1 && r.Header.Get("Content-Range") == "bytes 1000-1999/2500" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		mutex.Lock()
+		accepted = append(accepted, r.Header.Get("Content-Range"))
+		mutex.Unlock()
+	})
+	dir := t.TempDir()
+	queue, err := NewJobQueue(dir, server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{2500, 500} {
+		job := Job{Method: "PUT", URL: server.URL, FilePath: testFile(t, size), ChunkSize: 1000}
+		if _, err := queue.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the worker stops in the middle of the first job
+	job, ok, err := queue.Next()
+	if err != nil || !ok {
+		t.Fatalf("next job: %v %v", ok, err)
+	}
+	if err := queue.Run(job); err == nil {
+		t.Fatal("the failing job succeeded")
+	}
+
+	// a restarted worker finishes both jobs
+	atomic.StoreInt32(&failing, 0)
+	queue, err = NewJobQueue(dir, server.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		job, ok, err := queue.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		if err := queue.Run(job); err != nil {
+			t.Fatal(err)
+		}
+		if err := queue.Complete(job.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the committed first part isn't sent again
+	want := []string{"bytes 0-999/2500", "bytes 1000-1999/2500", "bytes 2000-2499/2500", "bytes 0-499/500"}
+	if !reflect.DeepEqual(accepted, want) {
+		t.Fatalf("accepted ranges %q, want %q", accepted, want)
+	}
+}
@@ -0,0 +1,49 @@
+package uploadbig
+
+import "fmt"
+
+// Leveled is a leveled logger the upload writes to, so the logs can go to
+// a structured logger such as zap or slog. The arguments are joined as by
+// fmt.Sprint.
+type Leveled interface {
+	Error(args ...interface{})
+	Info(args ...interface{})
+	Debug(args ...interface{})
+}
+
+// SetLogger replaces the Logger passed to New with logger
+func (c *UploadData) SetLogger(logger Leveled) {
+	c.logger = logger
+}
+
+// printLogger adapts the *log.Logger trio of a Logger to Leveled
+type printLogger struct {
+	logger *Logger
+}
+
+// the call depth 3 makes log.Lshortfile report the caller of the
+// debugf, infof or errorf helper
+
+func (l printLogger) Error(args ...interface{}) {
+	l.logger.ErrorLog.Output(3, fmt.Sprint(args...))
+}
+
+func (l printLogger) Info(args ...interface{}) {
+	l.logger.InfoLog.Output(3, fmt.Sprint(args...))
+}
+
+func (l printLogger) Debug(args ...interface{}) {
+	l.logger.DebugLog.Output(3, fmt.Sprint(args...))
+}
+
+func (c *UploadData) errorf(format string, args ...interface{}) {
+	c.logger.Error(fmt.Sprintf(format, args...))
+}
+
+func (c *UploadData) infof(format string, args ...interface{}) {
+	c.logger.Info(fmt.Sprintf(format, args...))
+}
+
+func (c *UploadData) debugf(format string, args ...interface{}) {
+	c.logger.Debug(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,66 @@
+package uploadbig
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryLogger keeps the lines logged at every level
+type memoryLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *memoryLogger) log(level string, args ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, level+" "+fmt.Sprint(args...))
+}
+
+func (l *memoryLogger) Error(args ...interface{}) { l.log("ERROR", args...) }
+func (l *memoryLogger) Info(args ...interface{})  { l.log("INFO", args...) }
+func (l *memoryLogger) Debug(args ...interface{}) { l.log("DEBUG", args...) }
+
+func TestLeveledLogger(t *testing.T) {
+	server := newTestServer(t, nil)
+	logger := &memoryLogger{}
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetLogger(logger)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	levels := map[string]bool{}
+	for _, line := range logger.lines {
+		levels[strings.Fields(line)[0]] = true
+		if strings.Contains(line, "\n") {
+			t.Fatalf("line %q has a newline", line)
+		}
+	}
+	if !levels["INFO"] || !levels["DEBUG"] {
+		t.Fatalf("levels %v in %q", levels, logger.lines)
+	}
+}
+
+func TestPrintLoggerCaller(t *testing.T) {
+	server := newTestServer(t, nil)
+	var output bytes.Buffer
+	logger := &Logger{
+		DebugLog: log.New(&output, "DEBUG ", log.Lshortfile),
+		InfoLog:  log.New(&output, "INFO ", log.Lshortfile),
+		ErrorLog: log.New(&output, "ERROR ", log.Lshortfile),
+	}
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, logger)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the adapter reports the caller of the logging helper
+	if output.Len() == 0 || strings.Contains(output.String(), "logger.go") {
+		t.Fatalf("output %q", output.String())
+	}
+}
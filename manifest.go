@@ -0,0 +1,92 @@
+package uploadbig
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"sort"
+)
+
+// PartInfo is a part accepted by the server, numbered from 1
+type PartInfo struct {
+	Number int    `json:"partNumber"`
+	ETag   string `json:"etag"`
+	Size   int    `json:"size"`
+}
+
+// ManifestEncoder builds the finalize request body from the uploaded parts
+type ManifestEncoder func(parts []PartInfo) (body []byte, contentType string, err error)
+
+// S3ManifestEncoder builds the CompleteMultipartUpload XML of S3
+func S3ManifestEncoder(parts []PartInfo) ([]byte, string, error) {
+	type s3Part struct {
+		PartNumber int
+		ETag       string
+	}
+	type completeMultipartUpload struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}
+
+	manifest := completeMultipartUpload{Parts: make([]s3Part, len(parts))}
+	for i, part := range parts {
+		manifest.Parts[i] = s3Part{PartNumber: part.Number, ETag: part.ETag}
+	}
+	body, err := xml.Marshal(manifest)
+	return body, "application/xml", err
+}
+
+// JSONManifestEncoder builds {"parts":[{"partNumber":1,"etag":"...","size":1024},...]}
+func JSONManifestEncoder(parts []PartInfo) ([]byte, string, error) {
+	body, err := json.Marshal(struct {
+		Parts []PartInfo `json:"parts"`
+	}{Parts: parts})
+	return body, "application/json", err
+}
+
+// SetFinalize makes the upload send request after the last chunk with the
+// body built by encoder from the parts' ETags and sizes. The ETags are
+// taken from the ETag header of the chunk responses.
+func (c *UploadData) SetFinalize(request FlushRequest, encoder ManifestEncoder) {
+	c.finalizeRequest = &request
+	c.manifestEncoder = encoder
+}
+
+// PartETags returns the parts accepted by the server in part order
+func (c *UploadData) PartETags() []PartInfo {
+	parts := append([]PartInfo(nil), c.parts...)
+	sort.Slice(parts, func(i, j int) bool {
+		return parts[i].Number < parts[j].Number
+	})
+	return parts
+}
+
+func (c *UploadData) finalize() error {
+	if c.finalizeRequest == nil {
+		return nil
+	}
+
+	body, contentType, err := c.manifestEncoder(c.PartETags())
+	if err != nil {
+		return err
+	}
+	request := *c.finalizeRequest
+	request.Headers = make(map[string]string, len(c.finalizeRequest.Headers)+1)
+	request.Headers["Content-Type"] = contentType
+	for name, value := range c.finalizeRequest.Headers {
+		request.Headers[name] = value
+	}
+	request.Headers = c.sharedHeaders(request.Headers)
+
+	for errorCount := 0; errorCount < c.maxAttempts(); errorCount++ {
+		err = flushRequest(c.ctx, c.client, c.id, request, body)
+		if err == nil {
+			c.debugf("Finalized %d parts", len(c.parts))
+			return nil
+		}
+		c.errorf("%v", err)
+		if !c.waitRetry(errorCount+1, 0) {
+			break
+		}
+	}
+	return err
+}
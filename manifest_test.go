@@ -0,0 +1,47 @@
+package uploadbig
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFinalizeManifest(t *testing.T) {
+	// parts complete out of order, the ETag names the part
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodPut {
+			var from, to, total int
+			fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &from, &to, &total)
+			time.Sleep(time.Duration(3-from/1000) * 5 * time.Millisecond)
+			w.Header().Set("ETag", fmt.Sprintf(`"e%d"`, from/1000+1))
+		}
+	})
+	u := New("PUT", server.URL+"/data", testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetConcurrency(3)
+	u.SetFinalize(FlushRequest{Method: "POST", URL: server.URL + "/complete"}, S3ManifestEncoder)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	finalize := requests[len(requests)-1]
+	if finalize.Method != http.MethodPost || finalize.Header.Get("Content-Type") != "application/xml" {
+		t.Fatalf("last request %s %s", finalize.Method, finalize.Header.Get("Content-Type"))
+	}
+	var parts []string
+	for i := 1; i <= 3; i++ {
+		parts = append(parts, fmt.Sprintf("<Part><PartNumber>%d</PartNumber><ETag>&#34;e%d&#34;</ETag></Part>", i, i))
+	}
+	want := "<CompleteMultipartUpload>" + strings.Join(parts, "") + "</CompleteMultipartUpload>"
+	if string(finalize.Body) != want {
+		t.Fatalf("finalize body %s, want %s", finalize.Body, want)
+	}
+
+	body, contentType, err := JSONManifestEncoder(u.PartETags())
+	wantJSON := `{"parts":[{"partNumber":1,"etag":"\"e1\"","size":1000},{"partNumber":2,"etag":"\"e2\"","size":1000},{"partNumber":3,"etag":"\"e3\"","size":500}]}`
+	if err != nil || contentType != "application/json" || string(body) != wantJSON {
+		t.Fatalf("JSON manifest %s %s %v", body, contentType, err)
+	}
+}
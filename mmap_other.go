@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package uploadbig
+
+import (
+	"errors"
+	"os"
+)
+
+func newMmapSource(file *os.File) (chunkSource, error) {
+	return nil, errors.New("mmap is not supported on this platform")
+}
@@ -0,0 +1,51 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package uploadbig
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// mmapSource reads chunks from the file mapped into memory
+type mmapSource struct {
+	file *os.File
+	data []byte
+}
+
+func newMmapSource(file *os.File) (chunkSource, error) {
+	fileStat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fileStat.Size() == 0 {
+		return nil, errEmptyMmap
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(fileStat.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapSource{file: file, data: data}, nil
+}
+
+func (s *mmapSource) ReadAt(part []byte, offset int64) (int, error) {
+	if offset >= int64(len(s.data)) {
+		return 0, io.EOF
+	}
+	n := copy(part, s.data[offset:])
+	if n < len(part) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (s *mmapSource) Close() error {
+	err := syscall.Munmap(s.data)
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
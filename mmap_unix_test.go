@@ -0,0 +1,31 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package uploadbig
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMmapSource(t *testing.T) {
+	server := newTestServer(t, nil)
+	size := 3*MB + 17
+	path := testFile(t, size)
+	u := New("PUT", server.URL, path, server.Client(), MB, nil)
+	u.SetMmap(true)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(server.Received(size), testContent(size)) {
+		t.Fatal("received content differs")
+	}
+
+	if err := u.openFile(); err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	if _, ok := u.file.(*mmapSource); !ok {
+		t.Fatalf("file is read through %T, not mmap", u.file)
+	}
+}
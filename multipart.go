@@ -0,0 +1,52 @@
+package uploadbig
+
+import (
+	"bytes"
+	"mime/multipart"
+	"sort"
+)
+
+// SetMultipart makes every chunk be sent as a multipart/form-data body with
+// the chunk in the fieldName file field. fields are written before the file
+// part in sorted key order, as some servers require the file to come last.
+// Chunks aren't compressed in multipart mode.
+func (c *UploadData) SetMultipart(fieldName string, fields map[string]string) {
+	c.multipartField = fieldName
+	c.multipartFields = make(map[string]string, len(fields))
+	for name, value := range fields {
+		c.multipartFields[name] = value
+	}
+}
+
+// multipartBody wraps part into a form and returns the body and its Content-Type
+func (c *UploadData) multipartBody(part []byte, fileName string) ([]byte, string, error) {
+	var buffer bytes.Buffer
+	writer := multipart.NewWriter(&buffer)
+
+	names := make([]string, 0, len(c.multipartFields))
+	for name := range c.multipartFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		err := writer.WriteField(name, c.multipartFields[name])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	fileWriter, err := writer.CreateFormFile(c.multipartField, fileName)
+	if err != nil {
+		return nil, "", err
+	}
+	_, err = fileWriter.Write(part)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	return buffer.Bytes(), writer.FormDataContentType(), nil
+}
@@ -0,0 +1,55 @@
+package uploadbig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"testing"
+)
+
+func TestMultipartFields(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 1500), server.Client(), 1000, nil)
+	u.SetMultipart("file", map[string]string{"token": "secret", "folder": "docs"})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	content := testContent(1500)
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(requests))
+	}
+	for i, request := range requests {
+		mediaType, params, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		if err != nil || mediaType != "multipart/form-data" {
+			t.Fatalf("chunk %d Content-Type %q", i, request.Header.Get("Content-Type"))
+		}
+		reader := multipart.NewReader(bytes.NewReader(request.Body), params["boundary"])
+		var names []string
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			body, _ := ioutil.ReadAll(part)
+			names = append(names, part.FormName())
+			switch part.FormName() {
+			case "file":
+				if !bytes.Equal(body, testChunk(content, i, 1000)) {
+					t.Errorf("chunk %d file content differs", i)
+				}
+			case "folder", "token":
+				if want := map[string]string{"folder": "docs", "token": "secret"}[part.FormName()]; string(body) != want {
+					t.Errorf("chunk %d field %s is %q", i, part.FormName(), body)
+				}
+			}
+		}
+		// the fields are sorted and precede the file
+		if want := []string{"folder", "token", "file"}; !reflect.DeepEqual(names, want) {
+			t.Errorf("chunk %d parts %q, want %q", i, names, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package uploadbig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeNotSatisfiable is returned when the server answers 416 and the
+// upload can't realign to the server's committed offset
+var ErrRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+const maxRealignments = 3
+
+// SetOffsetProbe sets the request asking the server how many bytes of the
+// session it has committed. The request is sent with the Session-ID header;
+// the answer is read from the Upload-Offset header or, failing that, from a
+// "Range: bytes=0-N" header. An empty method defaults to HEAD.
+//
+// When the server rejects a chunk with 416 Requested Range Not Satisfiable,
+// a file-backed upload probes the offset and continues from it.
+func (c *UploadData) SetOffsetProbe(method string, url string) {
+	if method == "" {
+		method = http.MethodHead
+	}
+	c.offsetProbeMethod = method
+	c.offsetProbeURL = url
+}
+
+func (c *UploadData) probeOffset() (int64, error) {
+	request, err := http.NewRequestWithContext(c.ctx, c.offsetProbeMethod, c.offsetProbeURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Session-ID", c.id)
+	setHeaders(request, c.sharedHeaders(nil))
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	c.debugf("Offset probe HTTP code %d", response.StatusCode)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return 0, fmt.Errorf("offset probe failed with HTTP code %d", response.StatusCode)
+	}
+	if response.Header.Get("Upload-Offset") == "" && response.Header.Get("Range") == "" {
+		return 0, nil
+	}
+	offset, err := parseOffsetHeaders(response.Header)
+	return c.localOffset(offset), err
+}
+
+// SetOffsetCompletion makes the offset the server reports in the chunk
+// responses (Upload-Offset or Range header) decide the progress: the upload
+// is done once it reaches the end of the file, even before the planned
+// parts have all been sent, and a file-backed upload continues from it when
+// the server has coalesced or cut a part.
+func (c *UploadData) SetOffsetCompletion(enabled bool) {
+	c.offsetCompletion = enabled
+}
+
+// followServerOffset applies the offset reported for part i to the plan
+func (c *UploadData) followServerOffset(i uint64, header http.Header) error {
+	if header.Get("Upload-Offset") == "" && header.Get("Range") == "" {
+		return nil
+	}
+	offset, err := parseOffsetHeaders(header)
+	if err != nil {
+		return err
+	}
+	return c.followOffset(i, c.localOffset(offset))
+}
+
+// followOffset continues the plan after part i from the server's offset
+func (c *UploadData) followOffset(i uint64, offset int64) error {
+	first := c.chunks[0].Offset
+	last := c.chunks[len(c.chunks)-1]
+	end := last.Offset + int64(last.Length)
+	switch {
+	case offset >= end:
+		c.chunks = c.chunks[:i+1]
+		c.statusMutex.Lock()
+		c.Status.Parts = i + 1
+		c.statusMutex.Unlock()
+		c.setSizeTransferred(end - first)
+		return nil
+	case i+1 == c.Status.Parts && (c.fromReader || c.wrapped != nil):
+		return fmt.Errorf("server offset %d is short of %d after the last part", offset, end)
+	case i+1 == c.Status.Parts:
+		// the rest of the last part becomes new parts
+	case offset == c.chunks[i+1].Offset:
+		return nil
+	case c.fromReader || c.wrapped != nil:
+		return fmt.Errorf("server offset %d differs from %d and a reader-backed upload can't realign", offset, c.chunks[i+1].Offset)
+	}
+	return c.realign(i+1, offset)
+}
+
+func parseOffsetHeaders(header http.Header) (int64, error) {
+	if uploadOffset := header.Get("Upload-Offset"); uploadOffset != "" {
+		return strconv.ParseInt(uploadOffset, 10, 64)
+	}
+
+	if byteRange := header.Get("Range"); byteRange != "" {
+		splitted := strings.Split(strings.TrimPrefix(byteRange, "bytes="), "-")
+		if len(splitted) != 2 {
+			return 0, fmt.Errorf("can't parse Range header %q", byteRange)
+		}
+		to, err := strconv.ParseInt(splitted[1], 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return to + 1, nil
+	}
+
+	return 0, nil
+}
+
+// handleRangeNotSatisfiable realigns the plan to the server's offset and
+// sends part i again, or fails the part with ErrRangeNotSatisfiable
+func (c *UploadData) handleRangeNotSatisfiable(i uint64, contentRange string, response chunkResponse) {
+	if c.offsetProbeURL == "" || c.fromReader || c.wrapped != nil || c.realignments >= maxRealignments {
+		c.errorf("%v", ErrRangeNotSatisfiable)
+		c.partFailed(i, contentRange, response, ErrRangeNotSatisfiable)
+		return
+	}
+
+	offset, err := c.probeOffset()
+	if err == nil {
+		err = c.realign(i, offset)
+	}
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrRangeNotSatisfiable, err)
+		c.errorf("%v", err)
+		c.partFailed(i, contentRange, response, err)
+		return
+	}
+
+	c.realignments++
+	c.uploadChunk(i)
+}
+
+// realign replaces the chunks from position i with chunks starting at offset
+func (c *UploadData) realign(i uint64, offset int64) error {
+	first := c.chunks[0].Offset
+	last := c.chunks[len(c.chunks)-1]
+	end := last.Offset + int64(last.Length)
+	if offset < first || offset > end {
+		return fmt.Errorf("server offset %d is outside of the upload [%d, %d)", offset, first, end)
+	}
+	if i == uint64(len(c.chunks)) {
+		c.infof("Add parts from offset %d", offset)
+	} else if offset == c.chunks[i].Offset {
+		return fmt.Errorf("server offset %d is the rejected part's offset", offset)
+	} else {
+		c.infof("Realign part %d from offset %d to %d", i, c.chunks[i].Offset, offset)
+	}
+
+	chunks := coalescePlan(buildRangePlan(offset, end, c.chunkSize), c.minPartSize, end)
+	err := validatePlan(chunks, offset, end)
+	if err != nil {
+		return fmt.Errorf("realigned plan is invalid: %w", err)
+	}
+	c.remoteRanges(chunks, end)
+	for n := range chunks {
+		chunks[n].Index = i + uint64(n)
+	}
+	c.chunks = append(c.chunks[:i:i], chunks...)
+	c.statusMutex.Lock()
+	c.Status.Parts = uint64(len(c.chunks))
+	c.Status.PartsTransferred = i
+	c.statusMutex.Unlock()
+	c.setSizeTransferred(offset - first)
+	return c.rehash(first, offset)
+}
+
+// rehashing reports whether the bytes the server has must be read again
+// to compute the checksums of the upload
+func (c *UploadData) rehashing() bool {
+	return c.crc32cHeader != "" || c.treeLeaves != nil || c.verifyDigest != nil
+}
+
+// rehash recomputes the checksums of the upload from the bytes in
+// [from, to), which the server already has. A reader-backed upload reads
+// them from the reader, moving it to offset to.
+func (c *UploadData) rehash(from int64, to int64) error {
+	if !c.rehashing() {
+		return nil
+	}
+	last := c.chunks[len(c.chunks)-1]
+	if c.treeLeaves != nil && to%MB != 0 && to != last.Offset+int64(last.Length) {
+		return fmt.Errorf("tree hash needs chunks aligned to 1 MB, server offset %d isn't", to)
+	}
+
+	c.crc32c = 0
+	c.startVerify()
+	for offset := from; offset < to; offset += MB {
+		// every part is a new buffer, tree hash leaves are hashed concurrently
+		part := make([]byte, partLength(offset, to, MB))
+		_, err := c.readChunk(ChunkSpec{Offset: offset, Length: len(part)}, part)
+		if err != nil {
+			return err
+		}
+		c.updateCRC32C(part)
+		c.hashTreeLeaves(offset, part)
+		c.hashVerified(offset, part)
+	}
+	return nil
+}
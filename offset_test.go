@@ -0,0 +1,82 @@
+package uploadbig
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestRangeNotSatisfiableRealigns(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Upload-Offset", "1500")
+			return
+		}
+		if n == 1 {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	u.SetOffsetProbe("", server.URL)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ranges []string
+	var last []byte
+	for _, request := range server.Requests() {
+		if request.Method != http.MethodHead {
+			ranges = append(ranges, request.Header.Get("Content-Range"))
+			last = request.Body
+		}
+	}
+	want := []string{"bytes 0-999/3000", "bytes 1000-1999/3000", "bytes 1500-2499/3000", "bytes 2500-2999/3000"}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges %q, want %q", ranges, want)
+	}
+	if !bytes.Equal(last, testContent(3000)[2500:]) {
+		t.Fatal("the realigned chunk content differs")
+	}
+	if u.Status.SizeTransferred != 3000 {
+		t.Fatalf("%d bytes transferred, want 3000", u.Status.SizeTransferred)
+	}
+}
+
+func TestRangeNotSatisfiableWithoutProbe(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	results, err := u.Diagnose()
+	if err == nil || len(results) < 2 || !errors.Is(results[1].Err, ErrRangeNotSatisfiable) {
+		t.Fatalf("results %+v, error %v", results, err)
+	}
+}
+
+func TestOffsetCompletion(t *testing.T) {
+	// the server coalesces the rest of the file after the second chunk
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		offset := (n + 1) * 1000
+		if n == 1 {
+			offset = 5000
+		}
+		w.Header().Set("Upload-Offset", strconv.Itoa(offset))
+	})
+	u := New("PUT", server.URL, testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetOffsetCompletion(true)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(server.Requests()); n != 2 {
+		t.Fatalf("sent %d chunks, want 2", n)
+	}
+	if !u.Status.IsDone || u.Status.SizeTransferred != 5000 {
+		t.Fatalf("status %+v", u.Status)
+	}
+}
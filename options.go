@@ -0,0 +1,92 @@
+package uploadbig
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+)
+
+// DefaultChunkSize is the chunk size of NewUploader without WithChunkSize
+const DefaultChunkSize = 5 * MB
+
+// Option configures an upload created by NewUploader
+type Option func(c *UploadData)
+
+// NewUploader creates new instance configured by opts. Without options it
+// uploads nothing: WithFile or WithReader sets the source. The client
+// defaults to http.DefaultClient and the chunk size to DefaultChunkSize.
+func NewUploader(method string, url string, opts ...Option) *UploadData {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	uploadData := &UploadData{
+		client:    http.DefaultClient,
+		method:    method,
+		url:       url,
+		id:        generateSessionID(),
+		chunkSize: DefaultChunkSize,
+		logger: printLogger{&Logger{
+			DebugLog: log.New(NewNullWriter(), "DEBUG\t", log.Ldate|log.Ltime|log.Lshortfile),
+			InfoLog:  log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+			ErrorLog: log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+		}},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for _, opt := range opts {
+		opt(uploadData)
+	}
+	return uploadData
+}
+
+// WithFile uploads the file at filePath
+func WithFile(filePath string) Option {
+	return func(c *UploadData) {
+		c.filePath = filePath
+	}
+}
+
+// WithReader uploads size bytes read from reader, as NewUploaderFromReader
+func WithReader(reader io.Reader, size int64) Option {
+	return func(c *UploadData) {
+		c.reader = reader
+		c.fromReader = true
+		c.size = size
+	}
+}
+
+// WithChunkSize sets the size of the chunks in bytes
+func WithChunkSize(chunkSize int) Option {
+	return func(c *UploadData) {
+		c.chunkSize = chunkSize
+	}
+}
+
+// WithClient sends the requests with client
+func WithClient(client *http.Client) Option {
+	return func(c *UploadData) {
+		c.client = client
+	}
+}
+
+// WithHeaders adds headers to every request, as SetAdditionalHeaders
+func WithHeaders(headers map[string]string) Option {
+	return func(c *UploadData) {
+		c.SetAdditionalHeaders(headers)
+	}
+}
+
+// WithLogger writes the logs to logger, as SetLogger
+func WithLogger(logger Leveled) Option {
+	return func(c *UploadData) {
+		c.SetLogger(logger)
+	}
+}
+
+// WithFileName sets the file name of the Content-Disposition header, as SetFileName
+func WithFileName(name string) Option {
+	return func(c *UploadData) {
+		c.SetFileName(name)
+	}
+}
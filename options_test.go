@@ -0,0 +1,47 @@
+package uploadbig
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNewUploaderOptions(t *testing.T) {
+	server := newTestServer(t, nil)
+	content := testContent(2500)
+	logger := &memoryLogger{}
+	u := NewUploader("PUT", server.URL,
+		WithReader(bytes.NewReader(content), 2500),
+		WithChunkSize(1000),
+		WithClient(server.Client()),
+		WithHeaders(map[string]string{"X-Tenant": "a"}),
+		WithLogger(logger),
+	)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a", "a", "a"}; !reflect.DeepEqual(server.Headers("X-Tenant"), want) {
+		t.Fatalf("headers %q, want %q", server.Headers("X-Tenant"), want)
+	}
+	if !bytes.Equal(server.Received(2500), content) {
+		t.Fatal("the uploaded content differs")
+	}
+	if len(logger.lines) == 0 {
+		t.Fatal("nothing was logged to the logger")
+	}
+}
+
+func TestNewUploaderDefaults(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := NewUploader("PUT", server.URL, WithFile(testFile(t, 100)))
+	if u.chunkSize != DefaultChunkSize {
+		t.Fatalf("chunk size %d, want %d", u.chunkSize, DefaultChunkSize)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Fatalf("%d requests, want 1", n)
+	}
+}
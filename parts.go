@@ -0,0 +1,52 @@
+package uploadbig
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// PartNameFunc returns the object name of part index out of total parts
+type PartNameFunc func(index uint64, total uint64) string
+
+// PartURLFunc returns the URL a part object named partName is uploaded to
+type PartURLFunc func(url string, partName string, index uint64) string
+
+// SetSeparateParts uploads every chunk as its own object named after the
+// file with a zero-padded part suffix (name.part000) and no Content-Range.
+// urlFunc chooses the URL of each part, nil keeps the upload URL and passes
+// the part name in Content-Disposition only.
+func (c *UploadData) SetSeparateParts(urlFunc PartURLFunc) {
+	c.separateParts = true
+	c.partURLFunc = urlFunc
+}
+
+// SetPartNameFunc names the part objects of SetSeparateParts with nameFunc
+// instead of the zero-padded suffix
+func (c *UploadData) SetPartNameFunc(nameFunc PartNameFunc) {
+	c.partNameFunc = nameFunc
+}
+
+// PartNames returns the names of the part objects uploaded so far
+func (c *UploadData) PartNames() []string {
+	return append([]string(nil), c.partNames...)
+}
+
+func (c *UploadData) partName(fileName string, index uint64) string {
+	if c.partNameFunc != nil {
+		return c.partNameFunc(index, c.Status.Parts)
+	}
+	width := 3
+	if c.Status.Parts > 0 {
+		if digits := len(strconv.FormatUint(c.Status.Parts-1, 10)); digits > width {
+			width = digits
+		}
+	}
+	return fmt.Sprintf("%s.part%0*d", fileName, width, index)
+}
+
+func (c *UploadData) partURL(partName string, index uint64) string {
+	if c.partURLFunc == nil {
+		return c.url
+	}
+	return c.partURLFunc(c.url, partName, index)
+}
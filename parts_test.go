@@ -0,0 +1,72 @@
+package uploadbig
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSeparateParts(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetSeparateParts(func(url string, partName string, index uint64) string {
+		return url + "/" + partName
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"upload.bin.part000", "upload.bin.part001", "upload.bin.part002"}
+	if got := u.PartNames(); !reflect.DeepEqual(got, names) {
+		t.Fatalf("part names %q, want %q", got, names)
+	}
+	content := testContent(2500)
+	requests := server.Requests()
+	if len(requests) != len(names) {
+		t.Fatalf("%d requests, want %d", len(requests), len(names))
+	}
+	for i, request := range requests {
+		if request.URL != "/"+names[i] {
+			t.Errorf("part %d sent to %s", i, request.URL)
+		}
+		if request.Header.Get("Content-Range") != "" {
+			t.Errorf("part %d has a Content-Range", i)
+		}
+		if !strings.Contains(request.Header.Get("Content-Disposition"), names[i]) {
+			t.Errorf("part %d disposition %q", i, request.Header.Get("Content-Disposition"))
+		}
+		end := (i + 1) * 1000
+		if end > len(content) {
+			end = len(content)
+		}
+		if !bytes.Equal(request.Body, content[i*1000:end]) {
+			t.Errorf("part %d content differs", i)
+		}
+	}
+}
+
+func TestPartNameFunc(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetSeparateParts(func(url string, partName string, index uint64) string {
+		return url + "/" + partName
+	})
+	u.SetPartNameFunc(func(index uint64, total uint64) string {
+		return fmt.Sprintf("chunk-%d-of-%d", index+1, total)
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"chunk-1-of-3", "chunk-2-of-3", "chunk-3-of-3"}
+	if got := u.PartNames(); !reflect.DeepEqual(got, names) {
+		t.Fatalf("part names %q, want %q", got, names)
+	}
+	for i, request := range server.Requests() {
+		if request.URL != "/"+names[i] {
+			t.Errorf("part %d sent to %s", i, request.URL)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrCertPinMismatch is returned when no certificate presented by the server
+// matches the pins set by SetCertPinning
+var ErrCertPinMismatch = errors.New("server certificate doesn't match the pinned keys")
+
+// SetCertPinning accepts only servers presenting a certificate whose public
+// key has one of the SHA-256 pins, hex or base64 encoded. A mismatch stops
+// the upload with ErrCertPinMismatch. See configureTransport.
+func (c *UploadData) SetCertPinning(sha256Pins ...string) error {
+	pins := make(map[[sha256.Size]byte]bool, len(sha256Pins))
+	for _, pin := range sha256Pins {
+		decoded, err := hex.DecodeString(pin)
+		if err != nil || len(decoded) != sha256.Size {
+			decoded, err = base64.StdEncoding.DecodeString(pin)
+		}
+		if err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("pin %q is not a hex or base64 SHA-256 hash", pin)
+		}
+		var key [sha256.Size]byte
+		copy(key[:], decoded)
+		pins[key] = true
+	}
+
+	return c.configureTransport(func(transport *http.Transport) {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				if pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)] {
+					return nil
+				}
+			}
+			return ErrCertPinMismatch
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCertPinning(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer server.Close()
+	pin := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	if err := u.SetCertPinning(hex.EncodeToString(pin[:])); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Fatalf("pinned server got %d requests, want 3", n)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	u = New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	if err := u.SetCertPinning(strings.Repeat("00", sha256.Size)); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(); !errors.Is(err, ErrCertPinMismatch) {
+		t.Fatalf("got error %v, want %v", err, ErrCertPinMismatch)
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Fatalf("non-matching server got %d requests", n)
+	}
+	if server.Client().Transport.(*http.Transport).TLSClientConfig.VerifyPeerCertificate != nil {
+		t.Fatal("the transport of the client passed to New was modified")
+	}
+}
+
+func TestCertPinningRejectsBadPins(t *testing.T) {
+	u := New("PUT", "https://localhost", testFile(t, 100), http.DefaultClient, 1000, nil)
+	if err := u.SetCertPinning("not a pin"); err == nil {
+		t.Fatal("bad pin accepted")
+	}
+}
@@ -0,0 +1,200 @@
+package uploadbig
+
+import (
+	"fmt"
+)
+
+// ChunkSpec describes a single chunk of the upload
+type ChunkSpec struct {
+	Index        uint64
+	Offset       int64
+	Length       int
+	ContentRange string
+}
+
+// Plan returns the chunks the upload is going to send. It is the plan
+// set by SetPlan or, if none, the one computed from the chunk size.
+func (c *UploadData) Plan() []ChunkSpec {
+	plan, err := c.currentPlan()
+	if err != nil {
+		c.errorf("%v", err)
+		return nil
+	}
+	return plan
+}
+
+// ContentRangeForPart returns the Content-Range the upload sends for the
+// part, with the configured prefix and suffix applied, without uploading
+func (c *UploadData) ContentRangeForPart(index uint64) (string, error) {
+	plan, err := c.currentPlan()
+	if err != nil {
+		return "", err
+	}
+	if index >= uint64(len(plan)) {
+		return "", fmt.Errorf("part %d is out of range, the upload has %d parts", index, len(plan))
+	}
+	if c.separateParts {
+		return "", nil
+	}
+	return c.decorateContentRange(plan, index), nil
+}
+
+func (c *UploadData) currentPlan() ([]ChunkSpec, error) {
+	if c.plan != nil {
+		return append([]ChunkSpec(nil), c.plan...), nil
+	}
+
+	size, err := c.sourceSize()
+	if err != nil {
+		return nil, err
+	}
+	return c.defaultPlan(size), nil
+}
+
+// SetPlan replaces the computed plan. The chunks must cover the whole file
+// contiguously in offset order. Indexes are renumbered by position and
+// every ContentRange is regenerated from Offset and Length.
+func (c *UploadData) SetPlan(plan []ChunkSpec) error {
+	size, err := c.sourceSize()
+	if err != nil {
+		return err
+	}
+
+	err = validatePlan(plan, 0, size)
+	if err != nil {
+		return err
+	}
+
+	c.plan = make([]ChunkSpec, len(plan))
+	for i, chunk := range plan {
+		chunk.Index = uint64(i)
+		chunk.ContentRange = formatContentRange(chunk.Offset, chunk.Offset+int64(chunk.Length)-1, size)
+		c.plan[i] = chunk
+	}
+	c.remoteRanges(c.plan, size)
+	return nil
+}
+
+// SetMinPartSize makes the computed plan merge chunks so no part except the
+// last one is shorter than size (e.g. the 5 MB minimum of S3). Zero disables it.
+func (c *UploadData) SetMinPartSize(size int) {
+	c.minPartSize = size
+}
+
+// SetRemoteTotalSize sets the final size of the remote object the upload is
+// appended to. It becomes the total of the content ranges, and the ranges
+// start at the end of the existing object, total - upload size. Offsets
+// reported by the server are read in the same coordinates.
+func (c *UploadData) SetRemoteTotalSize(total int64) {
+	c.remoteTotalSize = total
+}
+
+func (c *UploadData) defaultPlan(size int64) []ChunkSpec {
+	plan := coalescePlan(buildPlan(size, c.chunkSize), c.minPartSize, size)
+	c.remoteRanges(plan, size)
+	return plan
+}
+
+// remoteBase returns the offset in the remote object of the start of an
+// upload ending at end, zero without SetRemoteTotalSize
+func (c *UploadData) remoteBase(end int64) int64 {
+	if c.remoteTotalSize <= 0 {
+		return 0
+	}
+	return c.remoteTotalSize - end
+}
+
+// remoteRanges moves the ranges of a plan ending at end to the remote object
+func (c *UploadData) remoteRanges(plan []ChunkSpec, end int64) {
+	if c.remoteTotalSize <= 0 {
+		return
+	}
+	base := c.remoteBase(end)
+	for i := range plan {
+		from := base + plan[i].Offset
+		plan[i].ContentRange = formatContentRange(from, from+int64(plan[i].Length)-1, c.remoteTotalSize)
+	}
+}
+
+// localOffset converts an offset reported by the server to the file
+func (c *UploadData) localOffset(offset int64) int64 {
+	return offset - c.remoteBase(c.Status.Size)
+}
+
+// partLength returns the length of the part starting at offset: chunkSize,
+// or what is left up to end for the last part. Plans set by SetPlan
+// dictate their own lengths.
+func partLength(offset int64, end int64, chunkSize int) int {
+	if int64(chunkSize) > end-offset {
+		return int(end - offset)
+	}
+	return chunkSize
+}
+
+func buildPlan(size int64, chunkSize int) []ChunkSpec {
+	parts := uint64((size + int64(chunkSize) - 1) / int64(chunkSize))
+	plan := make([]ChunkSpec, parts)
+	for i := uint64(0); i < parts; i++ {
+		offset := int64(i * uint64(chunkSize))
+		partSize := partLength(offset, size, chunkSize)
+		plan[i] = ChunkSpec{
+			Index:        i,
+			Offset:       offset,
+			Length:       partSize,
+			ContentRange: generateContentRange(offset, partSize, size),
+		}
+	}
+	return plan
+}
+
+func buildRangePlan(from int64, to int64, chunkSize int) []ChunkSpec {
+	var plan []ChunkSpec
+	for offset := from; offset < to; offset += int64(chunkSize) {
+		partSize := partLength(offset, to, chunkSize)
+		plan = append(plan, ChunkSpec{
+			Index:        uint64(len(plan)),
+			Offset:       offset,
+			Length:       partSize,
+			ContentRange: formatContentRange(offset, offset+int64(partSize)-1, to),
+		})
+	}
+	return plan
+}
+
+func coalescePlan(plan []ChunkSpec, minPartSize int, totalSize int64) []ChunkSpec {
+	if minPartSize <= 0 {
+		return plan
+	}
+
+	var result []ChunkSpec
+	for _, chunk := range plan {
+		last := len(result) - 1
+		if last >= 0 && result[last].Length < minPartSize {
+			result[last].Length += chunk.Length
+			result[last].ContentRange = formatContentRange(result[last].Offset, result[last].Offset+int64(result[last].Length)-1, totalSize)
+			continue
+		}
+		chunk.Index = uint64(len(result))
+		result = append(result, chunk)
+	}
+	return result
+}
+
+// validatePlan checks that the chunks cover [from, to) contiguously, so their
+// lengths sum to to - from
+func validatePlan(plan []ChunkSpec, from int64, to int64) error {
+	next := from
+	for i, chunk := range plan {
+		if chunk.Length <= 0 {
+			return fmt.Errorf("plan chunk %d has non-positive length %d", i, chunk.Length)
+		}
+		if chunk.Offset != next {
+			return fmt.Errorf("plan chunk %d starts at %d, expected %d", i, chunk.Offset, next)
+		}
+		next = chunk.Offset + int64(chunk.Length)
+	}
+	if next != to {
+		return fmt.Errorf("plan covers [%d, %d), expected [%d, %d)", from, next, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package uploadbig
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSetPlan(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	plan := u.Plan()
+	if len(plan) != 3 || plan[2].ContentRange != "bytes 2000-2999/3000" {
+		t.Fatalf("computed plan %+v", plan)
+	}
+
+	// merge the last two chunks, keeping the stale range of the second one
+	plan[1].Length = 2000
+	if err := u.SetPlan(plan[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SetPlan(plan[:1]); err == nil {
+		t.Fatal("a plan not covering the file was accepted")
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"bytes 0-999/3000", "bytes 1000-2999/3000"}
+	if got := server.Headers("Content-Range"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+	for i, request := range server.Requests() {
+		if len(request.Body) != plan[i].Length {
+			t.Errorf("chunk %d has %d bytes, want %d", i, len(request.Body), plan[i].Length)
+		}
+	}
+}
+
+func TestMinPartSizeMergesUndersizedParts(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 400, nil)
+	u.SetMinPartSize(1000)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 400-byte chunks merge into parts of at least 1000 bytes; only the
+	// last part may stay shorter
+	want := []string{"bytes 0-1199/2500", "bytes 1200-2399/2500", "bytes 2400-2499/2500"}
+	if got := server.Headers("Content-Range"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+}
+
+func TestRemoteTotalSize(t *testing.T) {
+	// the 30 bytes complete a remote object of 130 bytes
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Upload-Offset", "115")
+			return
+		}
+		if n == 1 {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 30), server.Client(), 10, nil)
+	u.SetRemoteTotalSize(130)
+	u.SetOffsetProbe("", server.URL)
+	if got := u.Plan()[2].ContentRange; got != "bytes 120-129/130" {
+		t.Fatalf("last planned range %q", got)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ranges []string
+	for _, request := range server.Requests() {
+		if request.Method != http.MethodHead {
+			ranges = append(ranges, request.Header.Get("Content-Range"))
+		}
+	}
+	// the probed offset 115 is the local offset 15
+	want := []string{"bytes 100-109/130", "bytes 110-119/130", "bytes 115-124/130", "bytes 125-129/130"}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges %q, want %q", ranges, want)
+	}
+
+	plan := u.Plan()
+	plan[1].Length = 20
+	if err := u.SetPlan(plan[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Plan()[1].ContentRange; got != "bytes 110-129/130" {
+		t.Fatalf("set plan range %q", got)
+	}
+
+	u.SetContentRangeFunc(func(sessionID string, index uint64, from int64, to int64, total int64) string {
+		return fmt.Sprintf("%d:%d-%d/%d", index, from, to, total)
+	})
+	if got, err := u.ContentRangeForPart(1); err != nil || got != "1:110-129/130" {
+		t.Fatalf("range func got %q, %v", got, err)
+	}
+}
+
+func TestFinalPartLength(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 3001), server.Client(), 1000, nil)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("%d requests, want 4", len(requests))
+	}
+	last := requests[3]
+	if len(last.Body) != 1 || last.Header.Get("Content-Range") != "bytes 3000-3000/3001" {
+		t.Fatalf("final chunk of %d bytes, range %q", len(last.Body), last.Header.Get("Content-Range"))
+	}
+	for _, size := range []int64{0, 999, 1000, 3000} {
+		plan := buildPlan(size, 1000)
+		if want := int((size + 999) / 1000); len(plan) != want {
+			t.Errorf("size %d: %d parts, want %d", size, len(plan), want)
+		}
+		if len(plan) > 0 && plan[len(plan)-1].Length != int(size)-(len(plan)-1)*1000 {
+			t.Errorf("size %d: final part of %d bytes", size, plan[len(plan)-1].Length)
+		}
+	}
+}
+
+func TestContentRangeForPart(t *testing.T) {
+	u := New("PUT", "", testFile(t, 2500), nil, 1000, nil)
+	for _, test := range []struct {
+		index uint64
+		want  string
+	}{
+		{0, "bytes 0-999/2500"},
+		{1, "bytes 1000-1999/2500"},
+		{2, "bytes 2000-2499/2500"},
+	} {
+		got, err := u.ContentRangeForPart(test.index)
+		if err != nil || got != test.want {
+			t.Errorf("part %d: %q %v, want %q", test.index, got, err, test.want)
+		}
+	}
+	if _, err := u.ContentRangeForPart(3); err == nil {
+		t.Error("part 3 of 3 has a range")
+	}
+}
+
+func TestPlanSizesSumToSize(t *testing.T) {
+	for size := int64(0); size < 300; size++ {
+		for chunkSize := 1; chunkSize < 40; chunkSize++ {
+			for _, minPartSize := range []int{0, 7, 50} {
+				plan := coalescePlan(buildPlan(size, chunkSize), minPartSize, size)
+				var sum int64
+				for _, chunk := range plan {
+					sum += int64(chunk.Length)
+				}
+				if sum != size {
+					t.Fatalf("size %d, chunk size %d, min part size %d: parts sum to %d", size, chunkSize, minPartSize, sum)
+				}
+				if err := validatePlan(plan, 0, size); err != nil {
+					t.Fatalf("size %d, chunk size %d, min part size %d: %v", size, chunkSize, minPartSize, err)
+				}
+			}
+			if err := validatePlan(buildRangePlan(size/3, size, chunkSize), size/3, size); err != nil {
+				t.Fatalf("range plan from %d of size %d, chunk size %d: %v", size/3, size, chunkSize, err)
+			}
+		}
+	}
+
+	if err := validatePlan([]ChunkSpec{{Index: 0, Offset: 0, Length: 10}}, 0, 11); err == nil {
+		t.Fatal("a plan short of the size was accepted")
+	}
+}
@@ -0,0 +1,54 @@
+package uploadbig
+
+// SetPrefetchLastChunk makes a file upload read the last chunk from disk
+// while the one before it is being sent, so the final request isn't held
+// up by the read.
+func (c *UploadData) SetPrefetchLastChunk(enabled bool) {
+	c.prefetchLast = enabled
+}
+
+type prefetchedChunk struct {
+	chunk ChunkSpec
+	data  []byte
+	err   error
+	ready chan struct{}
+}
+
+// startPrefetch reads the last chunk in the background when part i is the
+// one before it
+func (c *UploadData) startPrefetch(i uint64) {
+	if !c.prefetchLast || c.fromReader || c.wrapped != nil || c.prefetched != nil || i+2 != c.Status.Parts {
+		return
+	}
+
+	prefetched := &prefetchedChunk{chunk: c.chunks[i+1], ready: make(chan struct{})}
+	c.prefetched = prefetched
+	go func() {
+		defer close(prefetched.ready)
+		prefetched.data = make([]byte, prefetched.chunk.Length)
+		_, prefetched.err = c.file.ReadAt(prefetched.data, prefetched.chunk.Offset)
+	}()
+}
+
+// takePrefetched returns the prefetched data of length bytes at offset, nil
+// if it wasn't prefetched or the plan has changed since
+func (c *UploadData) takePrefetched(offset int64, length int) ([]byte, error) {
+	prefetched := c.prefetched
+	if prefetched == nil {
+		return nil, nil
+	}
+	<-prefetched.ready
+	c.prefetched = nil
+	if prefetched.chunk.Offset != offset || prefetched.chunk.Length != length {
+		return nil, nil
+	}
+	return prefetched.data, prefetched.err
+}
+
+// waitPrefetch waits for a background read before the file is closed
+func (c *UploadData) waitPrefetch() {
+	if c.prefetched != nil {
+		<-c.prefetched.ready
+		c.prefetched = nil
+	}
+}
@@ -0,0 +1,43 @@
+package uploadbig
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPrefetchLastChunk(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Header.Get("Content-Range") == "bytes 1000-1999/2500" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetPrefetchLastChunk(true)
+	// the last chunk is read once the penultimate one has been sent
+	var prefetched, ready bool
+	u.SetReadFunc(func(read ReadFunc) ReadFunc {
+		return func(part []byte, offset int64) (int, error) {
+			if offset == 2000 && u.prefetched != nil {
+				prefetched = true
+				select {
+				case <-u.prefetched.ready:
+					ready = true
+				default:
+				}
+			}
+			return read(part, offset)
+		}
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !prefetched || !ready {
+		t.Fatalf("last chunk prefetched %v, ready %v", prefetched, ready)
+	}
+	if !bytes.Equal(server.Received(2500), testContent(2500)) {
+		t.Fatal("received content differs")
+	}
+}
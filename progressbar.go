@@ -0,0 +1,65 @@
+package uploadbig
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// SetProgressBar writes the progress of the upload to w: percent, bytes,
+// rate and ETA. On a terminal the line is redrawn in place, other writers
+// get a newline terminated line at most once a second. The line of the
+// last chunk is always written.
+func (c *UploadData) SetProgressBar(w io.Writer) {
+	bar := &progressBar{writer: w, interval: time.Second}
+	if file, ok := w.(*os.File); ok {
+		if info, err := file.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			bar.terminal = true
+			bar.interval = 100 * time.Millisecond
+		}
+	}
+	c.progressBar = bar
+}
+
+type progressBar struct {
+	writer   io.Writer
+	terminal bool
+	interval time.Duration
+	last     time.Time
+}
+
+func (c *UploadData) renderProgress() {
+	bar := c.progressBar
+	if bar == nil {
+		return
+	}
+	finished := c.Status.PartsTransferred >= c.Status.Parts
+	now := time.Now()
+	if !finished && now.Sub(bar.last) < bar.interval {
+		return
+	}
+	bar.last = now
+
+	status := c.Status
+	percent := status.Percent()
+	if status.Size <= 0 {
+		percent = 100
+	}
+	elapsed := now.Sub(c.sessionStarted).Seconds()
+	line := fmt.Sprintf("%5.1f%% %.1f/%.1f MB", percent, float64(status.SizeTransferred)/MB, float64(status.Size)/MB)
+	if elapsed > 0 && status.SizeTransferred > 0 {
+		rate := float64(status.SizeTransferred) / elapsed
+		eta := time.Duration(float64(status.BytesRemaining())/rate) * time.Second
+		line += fmt.Sprintf(" %.1f MB/s ETA %v", rate/MB, eta.Round(time.Second))
+	}
+
+	if !bar.terminal {
+		fmt.Fprintln(bar.writer, line)
+		return
+	}
+	fmt.Fprintf(bar.writer, "\r\033[K%s", line)
+	if finished {
+		fmt.Fprintln(bar.writer)
+	}
+}
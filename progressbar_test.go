@@ -0,0 +1,27 @@
+package uploadbig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	var output bytes.Buffer
+	u.SetProgressBar(&output)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a buffer is not a terminal: the first line, then the last one as the
+	// upload takes less than the interval
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], " 40.0% ") || !strings.HasPrefix(lines[1], "100.0% ") {
+		t.Fatalf("progress lines %q", lines)
+	}
+	if strings.Contains(output.String(), "\r") {
+		t.Fatalf("progress redrawn on a buffer: %q", output.String())
+	}
+}
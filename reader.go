@@ -0,0 +1,255 @@
+package uploadbig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+)
+
+// NewUploaderFromReader creates new instance uploading size bytes read from
+// reader. Chunks are read sequentially, so the reader doesn't need to be
+// seekable. A reader that is an io.Seeker is however seeked back and read
+// again when the read of a chunk fails partway; other readers fail the part.
+func NewUploaderFromReader(method string, url string, reader io.Reader, size int64, client *http.Client,
+	chunkSize int, logger *Logger) *UploadData {
+
+	uploadData := New(method, url, "", client, chunkSize, logger)
+	WithReader(reader, size)(uploadData)
+	return uploadData
+}
+
+// SetVerifySize makes a reader-backed upload check the declared size. A
+// seekable reader is measured before anything is sent; for other readers
+// the upload fails at the end if the reader has bytes left over.
+func (c *UploadData) SetVerifySize(enabled bool) {
+	c.verifySize = enabled
+}
+
+// validateSource checks that exactly one of the file path and a usable reader
+// is set and the sizes make sense
+func (c *UploadData) validateSource() error {
+	if c.chunkSize <= 0 {
+		return errors.New("chunkSize must be positive")
+	}
+	if err := c.validateSink(); err != nil {
+		return err
+	}
+	if !c.fromReader {
+		if c.filePath == "" {
+			return errors.New("neither file path nor reader is set")
+		}
+		return nil
+	}
+
+	if c.filePath != "" {
+		return errors.New("both file path and reader are set")
+	}
+	if c.reader == nil {
+		return errors.New("reader is nil")
+	}
+	if c.size < 0 {
+		return fmt.Errorf("reader size %d is negative", c.size)
+	}
+	value := reflect.ValueOf(c.reader)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.Interface, reflect.Slice:
+		if value.IsNil() {
+			return fmt.Errorf("reader is a nil %T", c.reader)
+		}
+	}
+	return nil
+}
+
+func (c *UploadData) sourceSize() (int64, error) {
+	err := c.validateSource()
+	if err != nil {
+		return 0, err
+	}
+	if c.fromReader {
+		return c.size, nil
+	}
+
+	fileStat, err := os.Stat(c.filePath)
+	if err != nil {
+		return 0, err
+	}
+	return fileStat.Size(), nil
+}
+
+func (c *UploadData) openSource() error {
+	var err error
+	if !c.fromReader {
+		err = c.openFile()
+	} else if c.verifySize {
+		err = c.measureReader()
+	}
+	if err != nil {
+		return err
+	}
+
+	c.wrapSource()
+	return nil
+}
+
+// ReadFunc reads len(dst) bytes of the source starting at offset
+type ReadFunc func(dst []byte, offset int64) (int, error)
+
+// SetReadFunc wraps the read of every chunk: wrap gets the standard read
+// and returns the one used by the upload, which may instrument it (read
+// latency, read-ahead) or replace it (decryption at rest). The standard read
+// fills dst completely or fails.
+func (c *UploadData) SetReadFunc(wrap func(read ReadFunc) ReadFunc) {
+	c.readWrap = wrap
+}
+
+func (c *UploadData) readChunk(chunk ChunkSpec, part []byte) (int, error) {
+	read := c.readSource
+	if c.readWrap != nil {
+		read = c.readWrap(read)
+	}
+	return read(part, chunk.Offset)
+}
+
+func (c *UploadData) readSource(part []byte, offset int64) (int, error) {
+	if c.wrapped != nil {
+		return c.readWrapped(part, offset)
+	}
+	if !c.fromReader {
+		data, err := c.takePrefetched(offset, len(part))
+		if data != nil || err != nil {
+			return copy(part, data), err
+		}
+		return c.file.ReadAt(part, offset)
+	}
+
+	// io.ReadFull keeps the chunk at len(part) however much a single Read
+	// of the reader would deliver
+	readBytes, err := io.ReadFull(checkedReader{c.deadlineReader()}, part)
+	for failed := 1; c.rereadable(err) && failed < c.maxAttempts(); failed++ {
+		c.errorf("Read at offset %d failed after %d bytes, read again: %v", offset, readBytes, err)
+		_, seekErr := c.reader.(io.Seeker).Seek(-int64(readBytes), io.SeekCurrent)
+		if seekErr != nil || !c.waitRetry(failed, 0) {
+			break
+		}
+		readBytes, err = io.ReadFull(checkedReader{c.deadlineReader()}, part)
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		err = fmt.Errorf("%w: no data for %v at %d bytes", ErrSourceReadTimeout, c.sourceReadTimeout, offset+int64(readBytes))
+	} else if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("reader ended at %d bytes, declared size is %d", offset+int64(readBytes), c.size)
+	}
+	return readBytes, err
+}
+
+// ErrSourceReadTimeout is returned when a read of the source stalls for
+// longer than the timeout set by SetSourceReadTimeout
+var ErrSourceReadTimeout = errors.New("source read timed out")
+
+// SetSourceReadTimeout fails the upload with ErrSourceReadTimeout when a
+// single read of the reader source, e.g. a relayed net.Conn, delivers
+// nothing for timeout, telling a stalled upstream from a stalled server.
+// It applies to readers with a SetReadDeadline method. Zero disables it.
+func (c *UploadData) SetSourceReadTimeout(timeout time.Duration) {
+	c.sourceReadTimeout = timeout
+}
+
+// deadlineReader returns the reader source, setting the read deadline
+// before every read when SetSourceReadTimeout applies
+func (c *UploadData) deadlineReader() io.Reader {
+	reader, ok := c.reader.(readDeadliner)
+	if c.sourceReadTimeout <= 0 || !ok {
+		return c.reader
+	}
+	return timedReader{reader, c.sourceReadTimeout}
+}
+
+type readDeadliner interface {
+	io.Reader
+	SetReadDeadline(t time.Time) error
+}
+
+type timedReader struct {
+	reader  readDeadliner
+	timeout time.Duration
+}
+
+func (r timedReader) Read(p []byte) (int, error) {
+	err := r.reader.SetReadDeadline(time.Now().Add(r.timeout))
+	if err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
+// rereadable reports whether a chunk read failing with err can be read
+// again: the reader is seekable and the error isn't the end of the reader
+func (c *UploadData) rereadable(err error) bool {
+	if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false
+	}
+	_, ok := c.reader.(io.Seeker)
+	return ok
+}
+
+// checkedReader fails a Read reporting more bytes than the buffer holds
+// instead of letting the count run past the chunk
+type checkedReader struct {
+	reader io.Reader
+}
+
+func (r checkedReader) Read(p []byte) (int, error) {
+	readBytes, err := r.reader.Read(p)
+	if readBytes > len(p) {
+		return 0, fmt.Errorf("reader returned %d bytes for a %d byte buffer", readBytes, len(p))
+	}
+	return readBytes, err
+}
+
+// measureReader compares the bytes left in a seekable reader to the declared size
+func (c *UploadData) measureReader() error {
+	seeker, ok := c.reader.(io.Seeker)
+	if !ok {
+		return nil
+	}
+
+	current, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	_, err = seeker.Seek(current, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	if end-current != c.size {
+		return fmt.Errorf("reader has %d bytes, declared size is %d", end-current, c.size)
+	}
+	return nil
+}
+
+// checkReaderDrained fails a non-seekable reader that has more bytes than declared
+func (c *UploadData) checkReaderDrained() error {
+	if !c.fromReader || !c.verifySize {
+		return nil
+	}
+	if _, ok := c.reader.(io.Seeker); ok {
+		return nil
+	}
+
+	readBytes, err := c.reader.Read(make([]byte, 1))
+	if readBytes > 0 {
+		return fmt.Errorf("reader has more bytes than declared size %d", c.size)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
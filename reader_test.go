@@ -0,0 +1,252 @@
+package uploadbig
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// streamReader hides every method of the reader but Read
+type streamReader struct {
+	io.Reader
+}
+
+func TestVerifySize(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	// a seekable reader is measured before anything is sent
+	u := NewUploaderFromReader("PUT", server.URL, bytes.NewReader(testContent(2500)), 2000, server.Client(), 1000, nil)
+	u.SetVerifySize(true)
+	if err := u.Init(); err == nil {
+		t.Fatal("a seekable reader longer than declared was accepted")
+	}
+	if got := len(server.Requests()); got != 0 {
+		t.Fatalf("%d chunks sent for a wrong size", got)
+	}
+
+	// other readers fail once the declared size is sent and bytes are left
+	u = NewUploaderFromReader("PUT", server.URL, streamReader{bytes.NewReader(testContent(2500))}, 2000, server.Client(), 1000, nil)
+	u.SetVerifySize(true)
+	if err := u.Init(); err == nil {
+		t.Fatal("a stream longer than declared was accepted")
+	}
+	if got := len(server.Requests()); got != 2 {
+		t.Fatalf("%d chunks sent, want 2", got)
+	}
+
+	u = NewUploaderFromReader("PUT", server.URL, streamReader{bytes.NewReader(testContent(2000))}, 2000, server.Client(), 1000, nil)
+	u.SetVerifySize(true)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSourceMisconfiguration(t *testing.T) {
+	var nilReader *bytes.Reader
+	for _, test := range []struct {
+		name     string
+		uploader *UploadData
+		want     string
+	}{
+		{"nil reader", NewUploaderFromReader("PUT", "", nil, 1, nil, 1, nil), "reader is nil"},
+		{"typed nil reader", NewUploaderFromReader("PUT", "", nilReader, 1, nil, 1, nil), "reader is a nil *bytes.Reader"},
+		{"neither", NewUploader("PUT", ""), "neither file path nor reader is set"},
+		{"both", NewUploader("PUT", "", WithFile("upload.bin"), WithReader(bytes.NewReader(nil), 0)), "both file path and reader are set"},
+	} {
+		if err := test.uploader.Init(); err == nil || err.Error() != test.want {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+// blockReader delivers its content in reads of the sizes in turn, as far
+// as the buffer allows
+type blockReader struct {
+	content []byte
+	sizes   []int
+	reads   int
+}
+
+func (r *blockReader) Read(p []byte) (int, error) {
+	if len(r.content) == 0 {
+		return 0, io.EOF
+	}
+	n := r.sizes[r.reads%len(r.sizes)]
+	r.reads++
+	if n > len(p) {
+		n = len(p)
+	}
+	n = copy(p[:n], r.content)
+	r.content = r.content[n:]
+	return n, nil
+}
+
+// overclaimingReader reports reading n bytes whatever the buffer size
+type overclaimingReader struct {
+	n int
+}
+
+func (r overclaimingReader) Read(p []byte) (int, error) {
+	return r.n, nil
+}
+
+func TestLargeReadsAreSplitIntoChunks(t *testing.T) {
+	size := 3*MB + 17
+	content := testContent(size)
+	for _, sizes := range [][]int{{10 * MB}, {1, 700 * 1024, 10 * MB, 3}} {
+		server := newTestServer(t, nil)
+		reader := &blockReader{content: content, sizes: sizes}
+		u := NewUploaderFromReader("PUT", server.URL, reader, int64(size), server.Client(), MB, nil)
+		if err := u.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		requests := server.Requests()
+		if len(requests) != 4 {
+			t.Fatalf("reads of %v: %d chunks, want 4", sizes, len(requests))
+		}
+		for i, request := range requests {
+			from := i * MB
+			to := from + len(testChunk(content, i, MB)) - 1
+			want := fmt.Sprintf("bytes %d-%d/%d", from, to, size)
+			if got := request.Header.Get("Content-Range"); got != want {
+				t.Errorf("reads of %v: chunk %d range %q, want %q", sizes, i, got, want)
+			}
+			if !bytes.Equal(request.Body, testChunk(content, i, MB)) {
+				t.Errorf("reads of %v: chunk %d content differs", sizes, i)
+			}
+		}
+	}
+}
+
+func TestOverclaimingReaderFails(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := NewUploaderFromReader("PUT", server.URL, overclaimingReader{10 * MB}, 2*MB, server.Client(), MB, nil)
+	if err := u.Init(); err == nil {
+		t.Fatal("a read reporting 10 MB into a 1 MB buffer was accepted")
+	}
+	if got := len(server.Requests()); got != 0 {
+		t.Fatalf("%d chunks sent", got)
+	}
+}
+
+func TestReadFuncSizes(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	var sizes []int
+	var offsets []int64
+	u.SetReadFunc(func(read ReadFunc) ReadFunc {
+		return func(part []byte, offset int64) (int, error) {
+			n, err := read(part, offset)
+			sizes = append(sizes, n)
+			offsets = append(offsets, offset)
+			return n, err
+		}
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []int{1000, 1000, 500}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("read sizes %v, want %v", sizes, want)
+	}
+	if want := []int64{0, 1000, 2000}; !reflect.DeepEqual(offsets, want) {
+		t.Errorf("read offsets %v, want %v", offsets, want)
+	}
+}
+
+func TestNonPositiveChunkSize(t *testing.T) {
+	server := newTestServer(t, nil)
+	for _, chunkSize := range []int{0, -1} {
+		u := New("PUT", server.URL, testFile(t, 100), server.Client(), chunkSize, nil)
+		if err := u.Init(); err == nil || err.Error() != "chunkSize must be positive" {
+			t.Errorf("chunk size %d: error %v", chunkSize, err)
+		}
+	}
+	if got := len(server.Requests()); got != 0 {
+		t.Fatalf("%d requests sent", got)
+	}
+}
+
+func TestSourceReadTimeout(t *testing.T) {
+	server := newTestServer(t, nil)
+	source, upstream := net.Pipe()
+	defer source.Close()
+	defer upstream.Close()
+	// the upstream stalls after a chunk and a half
+	go upstream.Write(testContent(1500))
+
+	u := NewUploaderFromReader("PUT", server.URL, source, 3000, server.Client(), 1000, nil)
+	u.SetSourceReadTimeout(100 * time.Millisecond)
+	start := time.Now()
+	err := u.Init()
+	if !errors.Is(err, ErrSourceReadTimeout) {
+		t.Fatalf("got error %v, want %v", err, ErrSourceReadTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("timed out after %v", elapsed)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Fatalf("%d chunks sent, want the complete one", n)
+	}
+}
+
+// failingSeeker fails a single read once it is past failAt
+type failingSeeker struct {
+	*bytes.Reader
+	failAt int64
+	failed bool
+}
+
+func (r *failingSeeker) Read(p []byte) (int, error) {
+	position, _ := r.Seek(0, io.SeekCurrent)
+	if !r.failed && position >= r.failAt {
+		r.failed = true
+		return 0, errors.New("connection reset")
+	}
+	// short reads so the failure comes in the middle of a chunk
+	if len(p) > 200 {
+		p = p[:200]
+	}
+	return r.Reader.Read(p)
+}
+
+func TestSeekerRereadsAfterReadError(t *testing.T) {
+	server := newTestServer(t, nil)
+	content := testContent(3000)
+	source := &failingSeeker{Reader: bytes.NewReader(content), failAt: 1300}
+	u := NewUploaderFromReader("PUT", server.URL, source, 3000, server.Client(), 1000, nil)
+	u.SetRetryBackoff(nil)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !source.failed {
+		t.Fatal("the reader didn't fail")
+	}
+	if n := len(server.Requests()); n != 3 {
+		t.Fatalf("%d chunks sent, want 3", n)
+	}
+	if !bytes.Equal(server.Received(3000), content) {
+		t.Fatal("the uploaded content differs")
+	}
+}
+
+func TestStreamReadErrorFails(t *testing.T) {
+	server := newTestServer(t, nil)
+	source := &failingSeeker{Reader: bytes.NewReader(testContent(3000)), failAt: 1300}
+	// without Seek the chunk can't be read again
+	u := NewUploaderFromReader("PUT", server.URL, streamReader{source}, 3000, server.Client(), 1000, nil)
+	u.SetRetryBackoff(nil)
+	if err := u.Init(); err == nil {
+		t.Fatal("a failed read of a stream was accepted")
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Fatalf("%d chunks sent, want 1", n)
+	}
+}
@@ -0,0 +1,54 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// RecordedRequest is a chunk request seen by a Recorder
+type RecordedRequest struct {
+	Method     string
+	URL        string
+	Header     http.Header
+	BodySHA256 string
+}
+
+// RecordedResponse is the server's answer seen by a Recorder
+type RecordedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// Recorder captures every chunk request and its response, e.g. to build a
+// fixture for replay. err is set when no response was received.
+type Recorder interface {
+	Record(request RecordedRequest, response RecordedResponse, err error)
+}
+
+// RecordTo makes every chunk request and its response be passed to recorder
+func (c *UploadData) RecordTo(recorder Recorder) {
+	c.recorder = recorder
+}
+
+func recordExchange(recorder Recorder, request *http.Request, part []byte, response chunkResponse, err error) {
+	if recorder == nil {
+		return
+	}
+	header := request.Header.Clone()
+	if header.Get(sseKeyHeader) != "" {
+		header.Set(sseKeyHeader, "REDACTED")
+	}
+	sum := sha256.Sum256(part)
+	recorder.Record(RecordedRequest{
+		Method:     request.Method,
+		URL:        request.URL.String(),
+		Header:     header,
+		BodySHA256: hex.EncodeToString(sum[:]),
+	}, RecordedResponse{
+		StatusCode: response.statusCode,
+		Header:     response.header,
+		Body:       response.body,
+	}, err)
+}
@@ -0,0 +1,53 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// memoryRecorder keeps the recorded exchanges in memory
+type memoryRecorder struct {
+	mutex     sync.Mutex
+	requests  []RecordedRequest
+	responses []RecordedResponse
+}
+
+func (m *memoryRecorder) Record(request RecordedRequest, response RecordedResponse, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests = append(m.requests, request)
+	m.responses = append(m.responses, response)
+}
+
+func TestRecorder(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("ETag", "etag")
+	})
+	u := New("PUT", server.URL+"/upload", testFile(t, 2000), server.Client(), 1000, nil)
+	recorder := &memoryRecorder{}
+	u.RecordTo(recorder)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.requests) != 2 {
+		t.Fatalf("%d exchanges recorded, want 2", len(recorder.requests))
+	}
+	content := testContent(2000)
+	for i, request := range recorder.requests {
+		sum := sha256.Sum256(testChunk(content, i, 1000))
+		if request.Method != "PUT" || request.URL != server.URL+"/upload" || request.BodySHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("request %d: %+v", i, request)
+		}
+		response := recorder.responses[i]
+		if response.StatusCode != http.StatusOK || response.Header.Get("ETag") != "etag" {
+			t.Errorf("response %d: %+v", i, response)
+		}
+	}
+	if got := recorder.requests[1].Header.Get("Content-Range"); got != "bytes 1000-1999/2000" {
+		t.Errorf("second range %q", got)
+	}
+}
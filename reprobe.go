@@ -0,0 +1,59 @@
+package uploadbig
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// SetOffsetReprobe makes a long upload ask the server for its committed
+// offset every everyParts parts and every interval plus a random jitter,
+// and continue from the server's offset when it disagrees. Zero disables
+// the respective trigger. The request is the one set by SetOffsetProbe.
+// Only file-backed uploads can realign.
+func (c *UploadData) SetOffsetReprobe(everyParts int, interval time.Duration, jitter time.Duration) {
+	c.reprobeEvery = everyParts
+	c.reprobeInterval = interval
+	c.reprobeJitter = jitter
+}
+
+func (c *UploadData) scheduleReprobe() {
+	c.nextReprobe = time.Time{}
+	if c.reprobeInterval > 0 {
+		delay := c.reprobeInterval
+		if c.reprobeJitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(c.reprobeJitter)))
+		}
+		c.nextReprobe = time.Now().Add(delay)
+	}
+	c.partsSinceReprobe = 0
+}
+
+func (c *UploadData) reprobeDue() bool {
+	if c.offsetProbeURL == "" {
+		return false
+	}
+	if c.reprobeEvery > 0 && c.partsSinceReprobe >= c.reprobeEvery {
+		return true
+	}
+	return !c.nextReprobe.IsZero() && !time.Now().Before(c.nextReprobe)
+}
+
+// reprobe compares the server's offset to the start of part i and
+// realigns the plan when they differ
+func (c *UploadData) reprobe(i uint64) error {
+	defer c.scheduleReprobe()
+
+	offset, err := c.probeOffset()
+	if err != nil {
+		c.errorf("Offset reprobe: %v", err)
+		return nil
+	}
+	if offset == c.chunks[i].Offset {
+		return nil
+	}
+	if c.fromReader || c.wrapped != nil {
+		return fmt.Errorf("server offset %d differs from %d and a reader-backed upload can't realign", offset, c.chunks[i].Offset)
+	}
+	return c.realign(i, offset)
+}
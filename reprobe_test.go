@@ -0,0 +1,51 @@
+package uploadbig
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReprobeRealigns(t *testing.T) {
+	// after three parts the server reports it kept only 2500 bytes
+	var committed int64
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodHead {
+			offset := atomic.LoadInt64(&committed)
+			if offset == 3000 {
+				offset = 2500
+			}
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+			return
+		}
+		atomic.AddInt64(&committed, 1000)
+	})
+	u := New("PUT", server.URL, testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetOffsetProbe("", server.URL)
+	u.SetOffsetReprobe(3, 0, 0)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	var ranges []string
+	var last []byte
+	for _, request := range server.Requests() {
+		if request.Method == http.MethodPut {
+			ranges = append(ranges, request.Header.Get("Content-Range"))
+			last = request.Body
+		}
+	}
+	want := []string{
+		"bytes 0-999/5000", "bytes 1000-1999/5000", "bytes 2000-2999/5000",
+		"bytes 2500-3499/5000", "bytes 3500-4499/5000", "bytes 4500-4999/5000",
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges %q, want %q", ranges, want)
+	}
+	if !bytes.Equal(last, testContent(5000)[4500:]) {
+		t.Fatal("the realigned content differs")
+	}
+}
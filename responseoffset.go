@@ -0,0 +1,73 @@
+package uploadbig
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ResponseOffsetMode tells how the number read from a chunk response is applied
+type ResponseOffsetMode int
+
+const (
+	// OffsetAdditive reads the number as the bytes of the part the server
+	// has accepted and adds it to Status.SizeTransferred
+	OffsetAdditive ResponseOffsetMode = iota
+	// OffsetAbsolute reads the number as the offset in the file the server
+	// has committed up to; the next part starts there
+	OffsetAbsolute
+)
+
+// SetResponseOffset sets how the chunk responses are read. field selects a
+// number in a JSON response body, with dots for nested objects, such as
+// "offset" for {"offset": 2097152} or "result.offset"; empty reads the body
+// as before. In OffsetAbsolute mode Status.SizeTransferred follows the
+// offset and a file-backed upload realigns the next part to it when the
+// server has committed less or more than was sent.
+func (c *UploadData) SetResponseOffset(mode ResponseOffsetMode, field string) {
+	c.offsetMode = mode
+	c.offsetField = field
+}
+
+// responseValue returns the number of the response for a part of partSize
+// bytes, before the offset mode is applied
+func (c *UploadData) responseValue(response chunkResponse, partSize int) (int64, error) {
+	if c.offsetField != "" {
+		return jsonField(response.body, c.offsetField)
+	}
+	if c.transferredSize != nil {
+		return c.transferredSize(response.body, partSize, c.Status)
+	}
+	return calculateTransferredSize(response.body, partSize, c.Status, c.requireOffsetEcho)
+}
+
+// jsonField returns the integer at the dotted path of the JSON body
+func jsonField(body string, path string) (int64, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse response body as JSON: %w", err)
+	}
+
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("response field %q isn't in an object", path)
+		}
+		value, ok = object[name]
+		if !ok {
+			return 0, fmt.Errorf("response has no field %q", path)
+		}
+	}
+
+	switch number := value.(type) {
+	case json.Number:
+		return strconv.ParseInt(number.String(), 10, 64)
+	case string:
+		return strconv.ParseInt(number, 10, 64)
+	}
+	return 0, fmt.Errorf("response field %q is %T, not a number", path, value)
+}
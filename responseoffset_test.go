@@ -0,0 +1,58 @@
+package uploadbig
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestResponseOffsetAbsolute(t *testing.T) {
+	// the server commits only 300 bytes of the first chunk
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		var from, to, total int64
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &from, &to, &total)
+		committed := to + 1
+		if n == 0 {
+			committed = 300
+		}
+		fmt.Fprintf(w, `{"result": {"offset": %d}}`, committed)
+	})
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetResponseOffset(OffsetAbsolute, "result.offset")
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if got := requests[1].Header.Get("Content-Range"); got != "bytes 300-1299/2500" {
+		t.Fatalf("second chunk %q, want it to start at the committed offset", got)
+	}
+	if !bytes.Equal(requests[1].Body, testContent(2500)[300:1300]) {
+		t.Fatal("the realigned chunk content differs")
+	}
+	if u.Status.SizeTransferred != 2500 {
+		t.Fatalf("transferred %d bytes", u.Status.SizeTransferred)
+	}
+}
+
+func TestResponseOffsetAdditive(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		fmt.Fprintf(w, `{"received": "%d"}`, r.ContentLength)
+	})
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetResponseOffset(OffsetAdditive, "received")
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if u.Status.SizeTransferred != 2500 {
+		t.Fatalf("transferred %d bytes", u.Status.SizeTransferred)
+	}
+
+	u = New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetResponseOffset(OffsetAdditive, "missing")
+	u.SetMaxRetries(0)
+	if err := u.Init(); err == nil {
+		t.Fatal("a response without the field was accepted")
+	}
+}
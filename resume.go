@@ -0,0 +1,154 @@
+package uploadbig
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Resume continues an interrupted upload of the same session: it asks the
+// server how many bytes it already has, with the request set by
+// SetOffsetProbe or else a HEAD to the upload URL answered with an
+// Upload-Offset or Range header, unless SetResumeStrategy selects another
+// probe, and sends the rest. An upload to a SizedSink asks the sink instead.
+// A reader-backed upload can resume only if the reader is an io.Seeker
+// positioned at the start of the content, other readers fail. With CRC32C
+// or a tree hash, the bytes the server has are read again to checksum them.
+func (c *UploadData) Resume() error {
+	return c.run(true)
+}
+
+// ResumeStrategy selects how Resume learns the server's offset
+type ResumeStrategy int
+
+const (
+	// ResumeOffsetHeaders reads the Upload-Offset or Range header of the
+	// offset probe
+	ResumeOffsetHeaders ResumeStrategy = iota
+	// ResumeRangeProbe sends a GET with "Range: bytes=0-0" to the offset
+	// probe URL, or else the upload URL, and reads the stored length from
+	// the total of the Content-Range, or from the Content-Length when the
+	// server ignores the range
+	ResumeRangeProbe
+)
+
+// SetResumeStrategy sets how Resume asks the server how many bytes it has
+func (c *UploadData) SetResumeStrategy(strategy ResumeStrategy) {
+	c.resumeStrategy = strategy
+}
+
+// probeStoredLength returns the stored length of the upload resource
+func (c *UploadData) probeStoredLength() (int64, error) {
+	url := c.offsetProbeURL
+	if url == "" {
+		url = c.url
+	}
+	request, err := http.NewRequestWithContext(c.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Session-ID", c.id)
+	setHeaders(request, c.sharedHeaders(nil))
+	request.Header.Set("Range", "bytes=0-0")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	// the body isn't needed, a server ignoring the range sends it all
+	defer response.Body.Close()
+
+	c.debugf("Range probe HTTP code %d", response.StatusCode)
+	switch {
+	case response.StatusCode == http.StatusPartialContent, response.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		total, err := parseContentRangeTotal(response.Header.Get("Content-Range"))
+		return c.localOffset(total), err
+	case response.StatusCode >= 200 && response.StatusCode <= 299 && response.ContentLength >= 0:
+		return c.localOffset(response.ContentLength), nil
+	}
+	return 0, fmt.Errorf("range probe failed with HTTP code %d", response.StatusCode)
+}
+
+// parseContentRangeTotal returns N of "bytes 0-0/N" or "bytes */N"
+func parseContentRangeTotal(contentRange string) (int64, error) {
+	slash := strings.LastIndex(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || slash < 0 {
+		return 0, fmt.Errorf("can't parse Content-Range %q", contentRange)
+	}
+	total, err := strconv.ParseInt(contentRange[slash+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse Content-Range %q: %w", contentRange, err)
+	}
+	return total, nil
+}
+
+// probeResumeOffset sends the offset probe, by default a HEAD to the upload URL
+func (c *UploadData) probeResumeOffset() (int64, error) {
+	if c.offsetProbeURL == "" {
+		c.offsetProbeMethod, c.offsetProbeURL = http.MethodHead, c.chunkURL()
+		defer func() {
+			c.offsetProbeMethod, c.offsetProbeURL = "", ""
+		}()
+	}
+	return c.probeOffset()
+}
+
+// resumePosition moves the upload to the server's offset and returns the
+// position of the plan to continue from
+func (c *UploadData) resumePosition() (uint64, error) {
+	// an empty file has nothing the server could have, it finishes like Init
+	if len(c.chunks) == 0 {
+		return 0, nil
+	}
+	var offset int64
+	var err error
+	if c.sink != nil {
+		offset, err = c.sinkOffset()
+	} else if c.resumeStrategy == ResumeRangeProbe {
+		offset, err = c.probeStoredLength()
+	} else {
+		offset, err = c.probeResumeOffset()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	first := c.chunks[0].Offset
+	last := c.chunks[len(c.chunks)-1]
+	end := last.Offset + int64(last.Length)
+	if offset < first || offset > end {
+		return 0, fmt.Errorf("server offset %d is outside of the upload [%d, %d)", offset, first, end)
+	}
+	if c.fromReader && offset > first {
+		seeker, ok := c.reader.(io.Seeker)
+		if !ok {
+			return 0, fmt.Errorf("can't resume at offset %d, reader %T isn't seekable", offset, c.reader)
+		}
+		// the reader is moved by reading the checksummed bytes again
+		if !c.rehashing() {
+			_, err = seeker.Seek(offset-first, io.SeekCurrent)
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+	c.infof("Resume upload %s at offset %d", c.id, offset)
+
+	position := uint64(0)
+	for position < c.Status.Parts && c.chunks[position].Offset+int64(c.chunks[position].Length) <= offset {
+		position++
+	}
+	if c.commits != nil {
+		c.commits.next = position
+	}
+	if position < c.Status.Parts && c.chunks[position].Offset != offset {
+		return position, c.realign(position, offset)
+	}
+	c.statusMutex.Lock()
+	c.Status.PartsTransferred = position
+	c.statusMutex.Unlock()
+	c.setSizeTransferred(offset - first)
+	return position, c.rehash(first, offset)
+}
@@ -0,0 +1,209 @@
+package uploadbig
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// offsetServer answers HEAD requests with offset in Upload-Offset
+func offsetServer(t *testing.T, offset int64) *testServer {
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		}
+	})
+}
+
+func sentRanges(server *testServer) []string {
+	var ranges []string
+	for _, request := range server.Requests() {
+		if request.Method != http.MethodHead {
+			ranges = append(ranges, request.Header.Get("Content-Range"))
+		}
+	}
+	return ranges
+}
+
+func TestResume(t *testing.T) {
+	server := offsetServer(t, 1500)
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"bytes 1500-2499/3000", "bytes 2500-2999/3000"}
+	if got := sentRanges(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+	if !bytes.Equal(server.Received(3000)[1500:], testContent(3000)[1500:]) {
+		t.Fatal("the resumed content differs")
+	}
+	if u.Status.SizeTransferred != 3000 || !u.Status.IsDone {
+		t.Fatalf("status %+v", u.Status)
+	}
+}
+
+func TestResumeReaderCRC32C(t *testing.T) {
+	server := offsetServer(t, 4)
+	content := []byte("123456789")
+	u := NewUploaderFromReader("PUT", server.URL, bytes.NewReader(content), int64(len(content)), server.Client(), 4, nil)
+	u.SetCRC32C("")
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the checksum covers the bytes the server had before
+	if got := u.CRC32C(); got != 0xE3069283 {
+		t.Fatalf("CRC32C %#x, want 0xe3069283", got)
+	}
+	want := []string{"bytes 4-7/9", "bytes 8-8/9"}
+	if got := sentRanges(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+	if got := server.Received(9)[4:]; !bytes.Equal(got, content[4:]) {
+		t.Fatalf("resumed content %q", got)
+	}
+}
+
+func TestResumeTreeHash(t *testing.T) {
+	server := offsetServer(t, MB)
+	content := testContent(2*MB + 100)
+	u := New("PUT", server.URL, testFile(t, len(content)), server.Client(), MB, nil)
+	u.SetTreeHash(true)
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	l0, l1, l2 := sha256Of(content[:MB]), sha256Of(content[MB:2*MB]), sha256Of(content[2*MB:])
+	if want := hex.EncodeToString(sha256Of(sha256Of(l0, l1), l2)); u.TreeHash() != want {
+		t.Fatalf("tree hash %s, want %s", u.TreeHash(), want)
+	}
+	if n := len(sentRanges(server)); n != 2 {
+		t.Fatalf("sent %d chunks, want 2", n)
+	}
+}
+
+func TestResumeTreeHashUnaligned(t *testing.T) {
+	server := offsetServer(t, MB/2)
+	u := New("PUT", server.URL, testFile(t, 2*MB), server.Client(), MB, nil)
+	u.SetTreeHash(true)
+	if err := u.Resume(); err == nil {
+		t.Fatal("resumed the tree hash at an unaligned offset")
+	}
+	if n := len(sentRanges(server)); n != 0 {
+		t.Fatalf("sent %d chunks", n)
+	}
+}
+
+func TestResumeRangeProbe(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if r.Method == http.MethodGet {
+			w.Header().Set("Content-Range", "bytes 0-0/2300")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write([]byte{0})
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetResumeStrategy(ResumeRangeProbe)
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if requests[0].Method != http.MethodGet || requests[0].Header.Get("Range") != "bytes=0-0" {
+		t.Fatalf("probe %s with Range %q", requests[0].Method, requests[0].Header.Get("Range"))
+	}
+	want := []string{"bytes 2300-3299/5000", "bytes 3300-4299/5000", "bytes 4300-4999/5000"}
+	var ranges []string
+	for _, request := range requests[1:] {
+		ranges = append(ranges, request.Header.Get("Content-Range"))
+	}
+	if !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges %q, want %q", ranges, want)
+	}
+	if !bytes.Equal(server.Received(5000)[2300:], testContent(5000)[2300:]) {
+		t.Fatal("the resumed content differs")
+	}
+}
+
+func TestParseContentRangeTotal(t *testing.T) {
+	for contentRange, want := range map[string]int64{"bytes 0-0/2300": 2300, "bytes */0": 0} {
+		if got, err := parseContentRangeTotal(contentRange); err != nil || got != want {
+			t.Errorf("%q: total %d, error %v, want %d", contentRange, got, err, want)
+		}
+	}
+	if _, err := parseContentRangeTotal("0-0/10"); err == nil {
+		t.Error("a Content-Range without unit was accepted")
+	}
+}
+
+func TestResumeAtPart(t *testing.T) {
+	server := offsetServer(t, 5*1000)
+	u := New("PUT", server.URL, testFile(t, 8000), server.Client(), 1000, nil)
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"bytes 5000-5999/8000", "bytes 6000-6999/8000", "bytes 7000-7999/8000"}
+	if got := sentRanges(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+	if u.Status.PartsTransferred != 8 {
+		t.Fatalf("%d parts transferred, want 8", u.Status.PartsTransferred)
+	}
+}
+
+func TestResumeAfterFailedInit(t *testing.T) {
+	// the server rejects the second chunk once and keeps what it accepted
+	var mutex sync.Mutex
+	var stored int64
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.Method == http.MethodHead {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(stored, 10))
+			return
+		}
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		stored += r.ContentLength
+	})
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	u.SetMaxRetries(0)
+	if err := u.Init(); err == nil {
+		t.Fatal("the rejected chunk was accepted")
+	}
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"bytes 0-999/3000", "bytes 1000-1999/3000", "bytes 1000-1999/3000", "bytes 2000-2999/3000"}
+	if got := sentRanges(server); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ranges %q, want %q", got, want)
+	}
+	if !u.Status.IsDone || u.Status.TransferredException || u.Status.SizeTransferred != 3000 {
+		t.Fatalf("status %+v", u.Status)
+	}
+}
+
+func TestResumeEmptyFile(t *testing.T) {
+	server := offsetServer(t, 0)
+	u := New("PUT", server.URL, testFile(t, 0), server.Client(), 1000, nil)
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	// no probe is needed and no chunk is sent
+	if n := len(server.Requests()); n != 0 {
+		t.Fatalf("sent %d requests", n)
+	}
+	if !u.Status.IsDone || u.Status.TransferredException {
+		t.Fatalf("status %+v", u.Status)
+	}
+}
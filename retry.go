@@ -0,0 +1,135 @@
+package uploadbig
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultAttempts is how many times a request is sent before giving up
+const defaultAttempts = 3
+
+// SetFailFast makes every request be sent once: the first failure stops
+// the upload and Init returns its cause unwrapped. Meant for smoke tests
+// where a failure should surface at once.
+func (c *UploadData) SetFailFast(enabled bool) {
+	c.failFast = enabled
+}
+
+// SetMaxRetries sets how many times a failed chunk, flush, commit or
+// finalize request is resent. Zero sends every request once. By default a
+// request is sent up to 3 times, i.e. retried twice.
+func (c *UploadData) SetMaxRetries(retries int) error {
+	if retries < 0 {
+		return fmt.Errorf("max retries %d is negative", retries)
+	}
+	c.attempts = retries + 1
+	return nil
+}
+
+// RetryBackoff returns the delay before resending a request that has failed
+// attempt times
+type RetryBackoff func(attempt int) time.Duration
+
+// maxRetryBackoff caps the delay of DefaultRetryBackoff
+const maxRetryBackoff = 30 * time.Second
+
+// DefaultRetryBackoff doubles the delay from 200ms with every failed
+// attempt up to 30s and adds up to 20% of random jitter
+func DefaultRetryBackoff(attempt int) time.Duration {
+	delay := 200 * time.Millisecond
+	for i := 1; i < attempt && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay/5)+1))
+}
+
+// SetRetryBackoff replaces DefaultRetryBackoff as the delay between the
+// attempts of a request. nil resends at once.
+func (c *UploadData) SetRetryBackoff(backoff RetryBackoff) {
+	c.retryBackoff = backoff
+	c.retryBackoffSet = true
+}
+
+// defaultMaxRetryAfter caps the wait requested by a Retry-After header
+const defaultMaxRetryAfter = time.Minute
+
+// SetMaxRetryAfter caps the wait a 429 or 503 response requests with its
+// Retry-After header, 1 minute by default. The wait replaces the backoff
+// and the response still counts as a failed attempt.
+func (c *UploadData) SetMaxRetryAfter(max time.Duration) {
+	c.maxRetryAfter = max
+}
+
+// retryAfter returns the wait requested by a 429 or 503 response, zero if none
+func (c *UploadData) retryAfter(response chunkResponse) time.Duration {
+	if response.statusCode != http.StatusTooManyRequests && response.statusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	value := response.header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = time.Until(date)
+	}
+
+	max := c.maxRetryAfter
+	if max == 0 {
+		max = defaultMaxRetryAfter
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// waitRetry sleeps before the attempt following failed ones, for retryAfter
+// if set or else the backoff. It returns false when the upload is cancelled
+// meanwhile.
+func (c *UploadData) waitRetry(failed int, retryAfter time.Duration) bool {
+	if failed >= c.maxAttempts() {
+		return true
+	}
+	delay := retryAfter
+	if delay <= 0 {
+		backoff := c.retryBackoff
+		if !c.retryBackoffSet {
+			backoff = DefaultRetryBackoff
+		}
+		if backoff != nil {
+			delay = backoff(failed)
+		}
+	}
+	if delay <= 0 {
+		return c.ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+func (c *UploadData) maxAttempts() int {
+	if c.failFast {
+		return 1
+	}
+	if c.attempts > 0 {
+		return c.attempts
+	}
+	return defaultAttempts
+}
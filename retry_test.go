@@ -0,0 +1,133 @@
+package uploadbig
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFailFast(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetFailFast(true)
+
+	start := time.Now()
+	err := u.Init()
+	if err == nil || err.Error() != "part 0 was not accepted, HTTP code 503" {
+		t.Fatalf("got error %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("failed after %v, Retry-After was honoured", elapsed)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Fatalf("sent %d requests, want 1", n)
+	}
+}
+
+func TestMaxRetries(t *testing.T) {
+	for _, test := range []struct {
+		retries  int
+		failures int
+		requests int
+		ok       bool
+	}{
+		{0, 0, 1, true},
+		{0, 1, 1, false},
+		{4, 4, 5, true},
+		{4, 5, 5, false},
+	} {
+		server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+			if n < test.failures {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+		u := New("PUT", server.URL, testFile(t, 500), server.Client(), 1000, nil)
+		if err := u.SetMaxRetries(test.retries); err != nil {
+			t.Fatal(err)
+		}
+		u.SetRetryBackoff(nil)
+		if err := u.Init(); (err == nil) != test.ok {
+			t.Errorf("%d retries, %d failures: got error %v", test.retries, test.failures, err)
+		}
+		if len(server.Requests()) != test.requests {
+			t.Errorf("%d retries, %d failures: sent %d requests", test.retries, test.failures, len(server.Requests()))
+		}
+	}
+
+	u := New("PUT", "http://localhost", testFile(t, 500), http.DefaultClient, 1000, nil)
+	if err := u.SetMaxRetries(-1); err == nil {
+		t.Fatal("negative max retries accepted")
+	}
+}
+
+func TestDefaultRetryBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{
+		1: 200 * time.Millisecond, 2: 400 * time.Millisecond, 3: 800 * time.Millisecond,
+		8: 25600 * time.Millisecond, 9: 30 * time.Second, 40: 30 * time.Second, 100: 30 * time.Second,
+	} {
+		if got := DefaultRetryBackoff(attempt); got < want || got > want+want/5 {
+			t.Errorf("attempt %d: delay %v, want %v plus up to 20%%", attempt, got, want)
+		}
+	}
+}
+
+func TestRetryBackoffElapsed(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n < 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 500), server.Client(), 1000, nil)
+
+	start := time.Now()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// 200ms and 400ms, each with up to 20% of jitter
+	if elapsed := time.Since(start); elapsed < 600*time.Millisecond || elapsed > 1200*time.Millisecond {
+		t.Fatalf("retried within %v, want 600ms to 720ms", elapsed)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 0 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 500), server.Client(), 1000, nil)
+
+	start := time.Now()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second || elapsed > 3*time.Second {
+		t.Fatalf("retried after %v, want 2s", elapsed)
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Fatalf("sent %d requests, want 2", n)
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	u := New("PUT", "http://localhost", testFile(t, 500), http.DefaultClient, 1000, nil)
+	response := chunkResponse{
+		statusCode: http.StatusTooManyRequests,
+		header:     http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}},
+	}
+	if delay := u.retryAfter(response); delay != defaultMaxRetryAfter {
+		t.Fatalf("delay %v, want the %v cap", delay, defaultMaxRetryAfter)
+	}
+	u.SetMaxRetryAfter(time.Second)
+	if delay := u.retryAfter(response); delay != time.Second {
+		t.Fatalf("delay %v, want the 1s cap", delay)
+	}
+	response.statusCode = http.StatusInternalServerError
+	if delay := u.retryAfter(response); delay != 0 {
+		t.Fatalf("delay %v for a 500 response", delay)
+	}
+}
@@ -0,0 +1,86 @@
+package uploadbig
+
+import "time"
+
+// TimeWindow is a daily window of local time, e.g. {22 * time.Hour,
+// 6 * time.Hour} for 22:00 to 06:00. A window ending before it starts
+// runs past midnight.
+type TimeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// SetSchedule makes the upload send chunks only within windows. Outside of
+// them it pauses before the next chunk until a window opens; cancelling
+// the context ends the pause. Chunks are not cut short when a window
+// closes. No windows sends at any time.
+func (c *UploadData) SetSchedule(windows ...TimeWindow) {
+	c.schedule = append([]TimeWindow(nil), windows...)
+}
+
+func (w TimeWindow) contains(offset time.Duration) bool {
+	if w.Start <= w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// nextWindow returns now if a window is open, else when the next one opens
+func (c *UploadData) nextWindow(now time.Time) time.Time {
+	year, month, day := now.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	var next time.Time
+	for _, window := range c.schedule {
+		if window.contains(now.Sub(midnight)) {
+			return now
+		}
+		start := midnight.Add(window.Start)
+		if !start.After(now) {
+			start = time.Date(year, month, day+1, 0, 0, 0, 0, now.Location()).Add(window.Start)
+		}
+		if next.IsZero() || start.Before(next) {
+			next = start
+		}
+	}
+	return next
+}
+
+// waitSchedule waits until a window of the schedule is open. It returns the
+// context error when the upload is cancelled first.
+func (c *UploadData) waitSchedule() error {
+	if len(c.schedule) == 0 {
+		return nil
+	}
+	now, after := c.clock()
+	for {
+		current := now()
+		next := c.nextWindow(current)
+		if !next.After(current) {
+			return nil
+		}
+		c.infof("Upload %s paused until %s", c.id, next.Format(time.RFC3339))
+		select {
+		case <-after(next.Sub(current)):
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		}
+	}
+}
+
+// clock returns the time functions of the schedule, replaceable for tests
+func (c *UploadData) clock() (func() time.Time, func(d time.Duration) <-chan time.Time) {
+	if c.clockNow == nil {
+		return time.Now, time.After
+	}
+	return c.clockNow, c.clockAfter
+}
+
+// scheduleOpen reports whether a chunk can be sent now
+func (c *UploadData) scheduleOpen() bool {
+	if len(c.schedule) == 0 {
+		return true
+	}
+	now, _ := c.clock()
+	current := now()
+	return !c.nextWindow(current).After(current)
+}
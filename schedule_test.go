@@ -0,0 +1,92 @@
+package uploadbig
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock jumps over the waits instead of sleeping
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	fired := make(chan time.Time, 1)
+	fired <- f.advance(d)
+	return fired
+}
+
+func (f *fakeClock) advance(d time.Duration) time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = f.now.Add(d)
+	return f.now
+}
+
+func TestSchedule(t *testing.T) {
+	for _, concurrency := range []int{1, 3} {
+		clock := &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+		var mutex sync.Mutex
+		var sent []string
+		// every chunk takes 3 hours; concurrent chunks are served one after
+		// the other, a shorter time keeps them in the window they were sent in
+		step := 3 * time.Hour
+		if concurrency > 1 {
+			step = 30 * time.Minute
+		}
+		server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			sent = append(sent, clock.Now().Format("02 15:04"))
+			clock.advance(step)
+		})
+		u := New("PUT", server.URL, testFile(t, 5000), server.Client(), 1000, nil)
+		u.SetConcurrency(concurrency)
+		u.SetSchedule(TimeWindow{22 * time.Hour, 6 * time.Hour})
+		u.clockNow, u.clockAfter = clock.Now, clock.After
+		if err := u.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		if u.Status.PartsTransferred != 5 || u.Status.SizeTransferred != 5000 {
+			t.Fatalf("concurrency %d: status %+v", concurrency, u.Status)
+		}
+		if concurrency > 1 {
+			for _, at := range sent {
+				if hour := at[3:5]; hour >= "06" && hour < "22" {
+					t.Errorf("concurrency %d: chunk sent at %s", concurrency, at)
+				}
+			}
+			continue
+		}
+		// the window closes after 3 chunks and reopens the next evening
+		want := []string{"01 22:00", "02 01:00", "02 04:00", "02 22:00", "03 01:00"}
+		if !reflect.DeepEqual(sent, want) {
+			t.Fatalf("sent at %q, want %q", sent, want)
+		}
+	}
+}
+
+func TestNextWindow(t *testing.T) {
+	u := New("PUT", "http://localhost", "", http.DefaultClient, 1000, nil)
+	u.SetSchedule(TimeWindow{9 * time.Hour, 17 * time.Hour})
+	for now, want := range map[time.Time]time.Time{
+		time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC): time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC):  time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, 1, 1, 18, 0, 0, 0, time.UTC): time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC),
+	} {
+		if got := u.nextWindow(now); !got.Equal(want) {
+			t.Errorf("at %v: next window %v, want %v", now, got, want)
+		}
+	}
+}
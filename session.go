@@ -0,0 +1,54 @@
+package uploadbig
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrSessionExpired is returned when the upload has run longer than the
+// age set by SetSessionMaxAge. The session's credentials or URLs have to be
+// refreshed before resuming.
+var ErrSessionExpired = errors.New("upload session expired")
+
+// SetSessionMaxAge stops the upload with ErrSessionExpired before a chunk
+// would be sent more than maxAge after Init or Append started, for signed
+// sessions with a limited lifetime. Zero disables the limit.
+func (c *UploadData) SetSessionMaxAge(maxAge time.Duration) {
+	c.sessionMaxAge = maxAge
+}
+
+func (c *UploadData) sessionExpired() bool {
+	return c.sessionMaxAge > 0 && time.Since(c.sessionStarted) >= c.sessionMaxAge
+}
+
+// SessionID returns the session ID sent in the Session-ID header
+func (c *UploadData) SessionID() string {
+	return c.id
+}
+
+// SetSessionIDHandler makes handler be called with the session ID whenever
+// it is set: right away with the ID generated by the constructor, then on
+// Reset, when SetContentHashID derives it and when Append restores it from
+// the state file, always before a chunk of the session is sent. The ID
+// can so be persisted before the server knows it.
+func (c *UploadData) SetSessionIDHandler(handler func(id string)) {
+	c.sessionIDHandler = handler
+	if handler != nil {
+		handler(c.id)
+	}
+}
+
+// Reset gives the upload a new session ID, so the next Init starts a new
+// upload on the server instead of continuing the previous one; Init clears
+// the status the previous run left. With SetContentHashID, Init derives the
+// same ID from the content again, so Reset has no effect there.
+func (c *UploadData) Reset() {
+	c.setSessionID(generateSessionID())
+}
+
+func (c *UploadData) setSessionID(id string) {
+	c.id = id
+	if c.sessionIDHandler != nil {
+		c.sessionIDHandler(id)
+	}
+}
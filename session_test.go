@@ -0,0 +1,77 @@
+package uploadbig
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSessionMaxAge(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	u := New("PUT", server.URL, testFile(t, 10000), server.Client(), 1000, nil)
+	u.SetSessionMaxAge(120 * time.Millisecond)
+
+	err := u.Init()
+	if !errors.Is(err, ErrSessionExpired) {
+		t.Fatalf("got error %v, want %v", err, ErrSessionExpired)
+	}
+	// about 3 chunks fit into the age, the rest are not sent
+	if n := len(server.Requests()); n == 0 || n >= 10 {
+		t.Fatalf("sent %d chunks of 10", n)
+	}
+}
+
+func TestSessionIDHandler(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 100), server.Client(), 1000, nil)
+	first := u.SessionID()
+	var ids []string
+	u.SetSessionIDHandler(func(id string) {
+		ids = append(ids, id)
+	})
+	u.Reset()
+
+	// the constructor's ID right away, then the one of Reset
+	if len(ids) != 2 || ids[0] != first || ids[1] == first || ids[1] != u.SessionID() {
+		t.Fatalf("session IDs %q, first %s", ids, first)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Headers("Session-ID"); len(got) != 1 || got[0] != ids[1] {
+		t.Fatalf("sent session IDs %q, want %s", got, ids[1])
+	}
+}
+
+func TestResetAfterFailedInit(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 3000), server.Client(), 1000, nil)
+	u.SetMaxRetries(0)
+	if err := u.Init(); err == nil {
+		t.Fatal("the rejected chunk was accepted")
+	}
+	first := u.SessionID()
+	u.Reset()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second upload starts over under the new ID with a clean status
+	ids := server.Headers("Session-ID")
+	if len(ids) != 5 || ids[0] != first || ids[1] != first || ids[2] == first || ids[2] != u.SessionID() {
+		t.Fatalf("sent session IDs %q, first %s", ids, first)
+	}
+	if got := server.Headers("Content-Range")[2]; got != "bytes 0-999/3000" {
+		t.Fatalf("second upload starts with %s", got)
+	}
+	if !u.Status.IsDone || u.Status.TransferredException || u.Status.SizeTransferred != 3000 {
+		t.Fatalf("status %+v", u.Status)
+	}
+}
@@ -0,0 +1,78 @@
+package uploadbig
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Shutdown stops a running Init or Append: in-flight chunk requests are
+// cancelled and no further chunks are sent. It waits until the upload has
+// stopped and the file has been closed and returns the error the upload
+// returned, which wraps context.Canceled when Shutdown interrupted it, or
+// nil when it had completed. When ctx is done first, ctx's error is
+// returned. The uploader can't be used after Shutdown.
+func (c *UploadData) Shutdown(ctx context.Context) error {
+	c.cancel()
+
+	c.runMutex.Lock()
+	running := c.running
+	c.runMutex.Unlock()
+	if running == nil {
+		return nil
+	}
+
+	select {
+	case <-running:
+		c.debugf("Upload %s shut down", c.id)
+		c.runMutex.Lock()
+		defer c.runMutex.Unlock()
+		return c.runErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetOverallTimeout limits the whole Init, Resume or Append, all chunks
+// and retries included, to timeout, after which it stops as if its context
+// had been cancelled and returns an error wrapping
+// context.DeadlineExceeded. A deadline of the context set by SetContext
+// still applies if it is earlier. Zero disables it.
+func (c *UploadData) SetOverallTimeout(timeout time.Duration) {
+	c.overallTimeout = timeout
+}
+
+// runError returns the error of the run context, naming the overall timeout
+// when it has expired
+func (c *UploadData) runError() error {
+	err := c.ctx.Err()
+	if err == context.DeadlineExceeded && c.parentCtx != nil && c.parentCtx.Err() == nil {
+		return fmt.Errorf("upload exceeded the overall timeout of %v: %w", c.overallTimeout, err)
+	}
+	return err
+}
+
+func (c *UploadData) startRun() {
+	c.sessionStarted = time.Now()
+	if c.overallTimeout > 0 {
+		c.parentCtx = c.ctx
+		c.ctx, c.stopTimeout = context.WithTimeout(c.ctx, c.overallTimeout)
+	}
+	c.runMutex.Lock()
+	c.running = make(chan struct{})
+	c.runMutex.Unlock()
+	c.startSamplers()
+}
+
+func (c *UploadData) endRun(err error) {
+	c.stopSamplers()
+	if c.parentCtx != nil {
+		c.stopTimeout()
+		c.ctx, c.parentCtx, c.stopTimeout = c.parentCtx, nil, nil
+	}
+	c.runMutex.Lock()
+	c.runErr = err
+	close(c.running)
+	c.running = nil
+	c.runMutex.Unlock()
+}
@@ -0,0 +1,88 @@
+package uploadbig
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForWorkers(t *testing.T) {
+	started := make(chan struct{}, 8)
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		started <- struct{}{}
+		select {
+		case <-time.After(5 * time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	u := New("PUT", server.URL, testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetConcurrency(3)
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- u.Init()
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	shutdownErr := u.Shutdown(ctx)
+	if !errors.Is(shutdownErr, context.Canceled) {
+		t.Fatalf("Shutdown returned %v, want context.Canceled", shutdownErr)
+	}
+	// Init returns right after the run has stopped
+	select {
+	case err := <-exited:
+		if err != shutdownErr {
+			t.Fatalf("Init returned %v, Shutdown %v", err, shutdownErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Init is still running after Shutdown returned")
+	}
+	if status := u.Snapshot(); !status.TransferredException || status.PartsTransferred != 0 {
+		t.Fatalf("status %+v", status)
+	}
+}
+
+func TestOverallTimeout(t *testing.T) {
+	// every request succeeds well within the client timeout
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	client := server.Client()
+	client.Timeout = time.Second
+	u := New("PUT", server.URL, testFile(t, 10000), client, 1000, nil)
+	u.SetOverallTimeout(350 * time.Millisecond)
+
+	start := time.Now()
+	err := u.Init()
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "overall") {
+		t.Fatalf("got error %v, want the overall timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 700*time.Millisecond {
+		t.Fatalf("stopped after %v", elapsed)
+	}
+}
+
+func TestOverallTimeoutEarlierContext(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	u := New("PUT", server.URL, testFile(t, 10000), server.Client(), 1000, nil)
+	u.SetContext(ctx)
+	u.SetOverallTimeout(5 * time.Second)
+
+	start := time.Now()
+	err := u.Init()
+	if !errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "overall") {
+		t.Fatalf("got error %v, want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("stopped after %v", elapsed)
+	}
+}
@@ -0,0 +1,98 @@
+package uploadbig
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// SetFallbackToSingleShot makes the upload send the whole content in one
+// request, without Content-Range, when the server doesn't support chunked
+// uploads: the first chunk is answered with 501 Not Implemented, or the
+// capability probe set by SetCapabilityProbe reports neither ranges nor
+// tus. The fallback only happens while nothing has been uploaded yet.
+func (c *UploadData) SetFallbackToSingleShot(enabled bool) {
+	c.fallbackSingleShot = enabled
+}
+
+// singleShotRefused reports whether the capability probe rules out chunks
+func (c *UploadData) singleShotRefused() bool {
+	if !c.fallbackSingleShot || (c.capabilityMethod == "" && c.capabilityURL == "") {
+		return false
+	}
+	capabilities, err := c.ProbeCapabilities()
+	if err != nil {
+		c.errorf("%v", err)
+		return false
+	}
+	return !capabilities.Ranges && !capabilities.Resumable
+}
+
+// fallsBackToSingleShot reports whether the failed first chunk upload
+// should be replaced by a single-shot upload
+func (c *UploadData) fallsBackToSingleShot(upload *chunkUpload) bool {
+	return c.fallbackSingleShot && !upload.isSuccess &&
+		upload.response.statusCode == http.StatusNotImplemented &&
+		upload.index == 0 && upload.chunk.Offset == 0 && c.Status.PartsTransferred == 0
+}
+
+// uploadSingleShot sends the whole content in one request. consumed is what
+// has already been read from a reader source.
+func (c *UploadData) uploadSingleShot(consumed []byte) {
+	c.infof("Upload %s: single-shot upload", c.id)
+
+	var body io.Reader
+	if c.fromReader {
+		body = io.MultiReader(bytes.NewReader(consumed), c.reader)
+		if c.verifyDigest != nil {
+			c.verifyDigest, c.verifyHashed = sha256.New(), c.Status.Size
+			body = io.TeeReader(body, c.verifyDigest)
+		}
+	} else {
+		body = io.NewSectionReader(c.file, 0, c.Status.Size)
+	}
+	request, err := http.NewRequestWithContext(c.ctx, c.method, c.url, body)
+	if c.checkError(err) {
+		return
+	}
+	request.ContentLength = c.Status.Size
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("Session-ID", c.id)
+	setHeaders(request, c.sharedHeaders(nil))
+	if c.contentType != "" {
+		request.Header.Set("Content-Type", c.contentType)
+	}
+	if c.requestMutator != nil && c.checkError(c.requestMutator(request)) {
+		return
+	}
+
+	response, err := c.client.Do(request)
+	if c.checkError(err) {
+		return
+	}
+	defer response.Body.Close()
+	responseBody, err := ioutil.ReadAll(response.Body)
+	if c.checkError(err) {
+		return
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		c.checkError(fmt.Errorf("single-shot upload failed with HTTP code %d", response.StatusCode))
+		return
+	}
+
+	c.setSizeTransferred(c.Status.Size)
+	c.statusMutex.Lock()
+	c.Status.PartsTransferred = c.Status.Parts
+	c.Status.LastStatusCode = response.StatusCode
+	c.Status.LastResponseHeader = response.Header
+	c.Status.LastResponseBody = string(responseBody)
+	c.statusMutex.Unlock()
+	c.emitProgress()
+	if c.checkError(c.verifyByDownload()) {
+		return
+	}
+	c.uploadDone(false)
+}
@@ -0,0 +1,78 @@
+package uploadbig
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// chunkingRefused rejects every chunk with 501 and accepts whole uploads
+func chunkingRefused(w http.ResponseWriter, r *http.Request, n int) {
+	if r.Header.Get("Content-Range") != "" {
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestFallbackToSingleShot(t *testing.T) {
+	server := newTestServer(t, chunkingRefused)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetFallbackToSingleShot(true)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("%d requests, want the chunk then the whole file", len(requests))
+	}
+	if got := requests[1].Header.Get("Content-Range"); got != "" {
+		t.Fatalf("single-shot upload sent Content-Range %q", got)
+	}
+	if !bytes.Equal(requests[1].Body, testContent(2500)) {
+		t.Fatal("the single-shot content differs")
+	}
+	if !u.Status.IsDone || u.Status.SizeTransferred != 2500 {
+		t.Fatalf("status %+v", u.Status)
+	}
+}
+
+func TestFallbackToSingleShotReader(t *testing.T) {
+	server := newTestServer(t, chunkingRefused)
+	content := testContent(2500)
+	u := NewUploaderFromReader("PUT", server.URL, streamReader{bytes.NewReader(content)}, 2500, server.Client(), 1000, nil)
+	u.SetFallbackToSingleShot(true)
+	u.SetMaxRetries(0)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the consumed first chunk is sent again ahead of the rest of the reader
+	var ranges []string
+	for _, request := range server.Requests() {
+		ranges = append(ranges, request.Header.Get("Content-Range"))
+	}
+	if want := []string{"bytes 0-999/2500", ""}; !reflect.DeepEqual(ranges, want) {
+		t.Fatalf("ranges %q, want %q", ranges, want)
+	}
+	if !bytes.Equal(server.Requests()[1].Body, content) {
+		t.Fatal("the single-shot content differs")
+	}
+}
+
+func TestNoFallbackAfterTheFirstChunk(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n > 0 {
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	})
+	u := NewUploaderFromReader("PUT", server.URL, streamReader{bytes.NewReader(testContent(2500))}, 2500, server.Client(), 1000, nil)
+	u.SetFallbackToSingleShot(true)
+	u.SetMaxRetries(0)
+	if err := u.Init(); err == nil {
+		t.Fatal("fell back after a chunk was accepted")
+	}
+	if n := len(server.Requests()); n != 2 {
+		t.Fatalf("%d requests, want 2", n)
+	}
+}
@@ -0,0 +1,95 @@
+package uploadbig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ChunkSink receives the chunks instead of the HTTP endpoint, e.g. to write
+// them to an SFTP server. WriteChunk is retried like an HTTP request and
+// Finalize is called once after the last chunk has been written.
+type ChunkSink interface {
+	WriteChunk(offset int64, data []byte) error
+	Finalize() error
+}
+
+// SizedSink is a ChunkSink that knows how many bytes it has stored. Resume
+// continues from there; a sink that isn't sized can't be resumed.
+type SizedSink interface {
+	ChunkSink
+	Size() (int64, error)
+}
+
+// SetSink makes the upload send the chunks to sink. Without a sink chunks
+// are sent over HTTP. A sink receives the bytes of the content, so it can't
+// be combined with compression or multipart bodies.
+func (c *UploadData) SetSink(sink ChunkSink) {
+	c.sink = sink
+}
+
+// chunkSender sends one attempt of a chunk: over HTTP, or to the sink set
+// by SetSink
+type chunkSender interface {
+	send(ctx context.Context, attempt chunkAttempt) (bool, chunkResponse, error)
+}
+
+// chunkAttempt is what an attempt at a chunk sends; url, headers and
+// fileName only apply to HTTP
+type chunkAttempt struct {
+	url          string
+	headers      map[string]string
+	offset       int64
+	body         []byte
+	contentRange string
+	fileName     string
+}
+
+func (c *UploadData) chunkSender() chunkSender {
+	if c.sink != nil {
+		return sinkSender{sink: c.sink}
+	}
+	return httpSender{c: c}
+}
+
+// sinkSender writes the chunks to a ChunkSink
+type sinkSender struct {
+	sink ChunkSink
+}
+
+func (s sinkSender) send(ctx context.Context, attempt chunkAttempt) (bool, chunkResponse, error) {
+	err := s.sink.WriteChunk(attempt.offset, attempt.body)
+	return err == nil, chunkResponse{}, err
+}
+
+// validateSink rejects the options encoding the chunks when a sink, which
+// expects the bytes of the content, is set
+func (c *UploadData) validateSink() error {
+	if c.sink == nil {
+		return nil
+	}
+	if c.compression {
+		return errors.New("a sink can't receive compressed chunks")
+	}
+	if c.multipartField != "" {
+		return errors.New("a sink can't receive multipart bodies")
+	}
+	return nil
+}
+
+// sinkOffset returns the offset of the file the sink has stored up to
+func (c *UploadData) sinkOffset() (int64, error) {
+	sized, ok := c.sink.(SizedSink)
+	if !ok {
+		return 0, fmt.Errorf("can't resume, sink %T doesn't implement SizedSink", c.sink)
+	}
+	size, err := sized.Size()
+	return c.localOffset(size), err
+}
+
+func (c *UploadData) finalizeSink() error {
+	if c.sink == nil {
+		return nil
+	}
+	return c.sink.Finalize()
+}
@@ -0,0 +1,95 @@
+package uploadbig
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// memorySink stores the chunks in memory, failing the first fails writes
+type memorySink struct {
+	mutex     sync.Mutex
+	data      []byte
+	fails     int
+	writes    int
+	finalized bool
+}
+
+func (m *memorySink) WriteChunk(offset int64, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.writes++
+	if m.fails > 0 {
+		m.fails--
+		return errors.New("write failed")
+	}
+	if end := offset + int64(len(data)); end > int64(len(m.data)) {
+		m.data = append(m.data, make([]byte, end-int64(len(m.data)))...)
+	}
+	copy(m.data[offset:], data)
+	return nil
+}
+
+func (m *memorySink) Finalize() error {
+	m.finalized = true
+	return nil
+}
+
+func (m *memorySink) Size() (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return int64(len(m.data)), nil
+}
+
+func TestSink(t *testing.T) {
+	sink := &memorySink{fails: 1}
+	u := New("PUT", "", testFile(t, 2500), nil, 1000, nil)
+	u.SetSink(sink)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sink.data, testContent(2500)) || !sink.finalized {
+		t.Fatalf("sink has %d bytes, finalized %v", len(sink.data), sink.finalized)
+	}
+	if sink.writes != 4 {
+		t.Fatalf("%d writes, want the failed one retried", sink.writes)
+	}
+}
+
+func TestSinkResume(t *testing.T) {
+	content := testContent(2500)
+	sink := &memorySink{data: append([]byte(nil), content[:1000]...)}
+	u := New("PUT", "", testFile(t, 2500), nil, 1000, nil)
+	u.SetSink(sink)
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sink.data, content) || sink.writes != 2 {
+		t.Fatalf("sink has %d bytes after %d writes", len(sink.data), sink.writes)
+	}
+
+	u = New("PUT", "", testFile(t, 2500), nil, 1000, nil)
+	u.SetSink(struct{ ChunkSink }{sink})
+	if err := u.Resume(); err == nil {
+		t.Fatal("a sink without a size was resumed")
+	}
+}
+
+func TestSinkRejectsEncodedChunks(t *testing.T) {
+	for name, set := range map[string]func(u *UploadData){
+		"compression": func(u *UploadData) { u.SetCompression(true) },
+		"multipart":   func(u *UploadData) { u.SetMultipart("file", nil) },
+	} {
+		sink := &memorySink{}
+		u := New("PUT", "", testFile(t, 2500), nil, 1000, nil)
+		u.SetSink(sink)
+		set(u)
+		if err := u.Init(); err == nil {
+			t.Errorf("%s: the sink accepted encoded chunks", name)
+		}
+		if sink.writes != 0 {
+			t.Errorf("%s: %d chunks written", name, sink.writes)
+		}
+	}
+}
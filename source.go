@@ -0,0 +1,41 @@
+package uploadbig
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// chunkSource reads the chunks of a file-backed upload
+type chunkSource interface {
+	io.ReaderAt
+	io.Closer
+}
+
+var errEmptyMmap = errors.New("can't map an empty file")
+
+// SetMmap makes a file-backed upload read the chunks from the file mapped
+// into memory, which avoids re-reading retried chunks from disk. Platforms
+// without mmap fall back to ReadAt. The mapping is released by Close.
+func (c *UploadData) SetMmap(enabled bool) {
+	c.mmap = enabled
+}
+
+func (c *UploadData) openFile() error {
+	file, err := os.Open(c.filePath)
+	if err != nil {
+		return err
+	}
+
+	if c.mmap {
+		source, err := newMmapSource(file)
+		if err == nil {
+			c.file = source
+			return nil
+		}
+		c.debugf("Read %s without mmap: %v", c.filePath, err)
+	}
+
+	c.file = file
+	return nil
+}
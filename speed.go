@@ -0,0 +1,86 @@
+package uploadbig
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type speedSampler struct {
+	interval time.Duration
+	samples  chan float64
+}
+
+// SpeedSamples returns a channel receiving the upload rate in bytes per
+// second over the last interval, every interval. It must be called before
+// Init; the channel is closed when the upload finishes. A sample is dropped
+// if the previous one hasn't been received yet.
+func (c *UploadData) SpeedSamples(interval time.Duration) <-chan float64 {
+	samples := make(chan float64, 1)
+	c.samplers = append(c.samplers, speedSampler{interval: interval, samples: samples})
+	return samples
+}
+
+func (c *UploadData) setSizeTransferred(size int64) {
+	c.statusMutex.Lock()
+	c.Status.SizeTransferred = size
+	c.statusMutex.Unlock()
+	atomic.StoreInt64(&c.sampledSize, size)
+}
+
+func (c *UploadData) startSamplers() {
+	if len(c.samplers) == 0 {
+		return
+	}
+
+	c.samplersStop = make(chan struct{})
+	for _, sampler := range c.samplers {
+		c.samplersWait.Add(1)
+		go runSampler(sampler, c.sampledTransferred, c.samplersStop, &c.samplersWait)
+	}
+	c.samplers = nil
+}
+
+func (c *UploadData) stopSamplers() {
+	if c.samplersStop == nil {
+		return
+	}
+	close(c.samplersStop)
+	c.samplersWait.Wait()
+	c.samplersStop = nil
+}
+
+// sampledTransferred returns the transferred size for the samplers
+func (c *UploadData) sampledTransferred() int64 {
+	return atomic.LoadInt64(&c.sampledSize)
+}
+
+func runSampler(sampler speedSampler, transferred func() int64, stop chan struct{}, wait *sync.WaitGroup) {
+	defer wait.Done()
+	defer close(sampler.samples)
+
+	ticker := time.NewTicker(sampler.interval)
+	defer ticker.Stop()
+
+	last := transferred()
+	lastTime := time.Now()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			size := transferred()
+			delta := size - last
+			if delta < 0 {
+				delta = 0
+			}
+			rate := float64(delta) / now.Sub(lastTime).Seconds()
+			last, lastTime = size, now
+
+			select {
+			case sampler.samples <- rate:
+			default:
+			}
+		}
+	}
+}
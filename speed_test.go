@@ -0,0 +1,38 @@
+package uploadbig
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSpeedSamples(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		time.Sleep(30 * time.Millisecond)
+	})
+	u := New("PUT", server.URL, testFile(t, 10000), server.Client(), 1000, nil)
+	interval := 20 * time.Millisecond
+	samples := u.SpeedSamples(interval)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- u.Init()
+	}()
+	// no interval can see more than the whole file
+	bound := 10000 / interval.Seconds()
+	count := 0
+	for rate := range samples {
+		count++
+		if rate < 0 || rate > bound {
+			t.Errorf("sample %f is outside [0, %f]", rate, bound)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// ten chunks of 30ms span 15 intervals; a loaded machine drops ticks,
+	// so only a third of them are required
+	if count < 5 {
+		t.Fatalf("%d samples, want at least 5", count)
+	}
+}
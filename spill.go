@@ -0,0 +1,57 @@
+package uploadbig
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// SetSpillDir makes an upload from a non-seekable reader write every chunk
+// to a temporary file in dir and read it back for each attempt, so retries
+// send the same bytes while the chunk isn't held in memory between
+// attempts. The file is removed once the chunk is done, successfully or not.
+func (c *UploadData) SetSpillDir(dir string) {
+	c.spillDir = dir
+}
+
+// releaseSpill removes the spill file of a sent chunk, reading the bytes
+// back first when the single-shot fallback needs them
+func (c *UploadData) releaseSpill(upload *chunkUpload) error {
+	if upload.spillPath == "" {
+		return nil
+	}
+	path := upload.spillPath
+	upload.spillPath = ""
+	defer os.Remove(path)
+	if upload.data != nil || !c.fallsBackToSingleShot(upload) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	upload.data = data
+	return err
+}
+
+// spillChunk writes body to a temporary file and returns its path, or an
+// empty path when the chunk doesn't need to be spilled
+func (c *UploadData) spillChunk(body []byte) (string, error) {
+	if c.spillDir == "" || !c.fromReader {
+		return "", nil
+	}
+	if _, ok := c.reader.(io.Seeker); ok {
+		return "", nil
+	}
+
+	file, err := ioutil.TempFile(c.spillDir, "chunk-"+c.id+"-")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,85 @@
+package uploadbig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func spilledFiles(t *testing.T, dir string) int {
+	t.Helper()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestSpillRetrySendsSameBytes(t *testing.T) {
+	dir := t.TempDir()
+	var spilled int
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 0 {
+			spilled = spilledFiles(t, dir)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	content := testContent(1500)
+	u := NewUploaderFromReader("PUT", server.URL, streamReader{bytes.NewReader(content)}, 1500, server.Client(), 1000, nil)
+	u.SetSpillDir(dir)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if spilled != 1 {
+		t.Fatalf("%d spill files during the first attempt, want 1", spilled)
+	}
+	if left := spilledFiles(t, dir); left != 0 {
+		t.Fatalf("%d spill files left", left)
+	}
+	requests := server.Requests()
+	if len(requests) != 3 || !bytes.Equal(requests[0].Body, requests[1].Body) {
+		t.Fatalf("%d requests, the retry must resend the first chunk", len(requests))
+	}
+	if !bytes.Equal(server.Received(1500), content) {
+		t.Fatal("received content differs")
+	}
+}
+
+func TestSpilledChunkIsReleased(t *testing.T) {
+	u := NewUploaderFromReader("PUT", "", streamReader{bytes.NewReader(testContent(1500))}, 1500, nil, 1000, nil)
+	u.SetSpillDir(t.TempDir())
+	if err := u.validateSource(); err != nil {
+		t.Fatal(err)
+	}
+	u.chunks = u.defaultPlan(1500)
+	u.Status.Parts = uint64(len(u.chunks))
+	upload := u.prepareChunk(0)
+	if upload == nil || upload.spillPath == "" {
+		t.Fatal("the chunk was not spilled")
+	}
+	defer u.releaseSpill(upload)
+	if upload.data != nil || upload.body != nil {
+		t.Fatal("the spilled chunk is still held in memory")
+	}
+}
+
+func TestSpillSingleShotFallback(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 0 {
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+	})
+	content := testContent(2500)
+	u := NewUploaderFromReader("PUT", server.URL, streamReader{bytes.NewReader(content)}, 2500, server.Client(), 1000, nil)
+	u.SetSpillDir(t.TempDir())
+	u.SetFallbackToSingleShot(true)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 || !bytes.Equal(requests[1].Body, content) {
+		t.Fatalf("%d requests, want the whole content in the second one", len(requests))
+	}
+}
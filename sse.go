@@ -0,0 +1,25 @@
+package uploadbig
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+)
+
+const (
+	sseAlgorithmHeader = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	sseKeyHeader       = "X-Amz-Server-Side-Encryption-Customer-Key"
+	sseKeyMD5Header    = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+)
+
+// SetSSECustomerKey sends the S3 SSE-C headers for the AES256 key with
+// every request of the upload, as S3 requires them on each part as well
+// as on the finalize request. The key is never logged and is redacted in
+// the requests passed to a Recorder.
+func (c *UploadData) SetSSECustomerKey(key []byte) {
+	sum := md5.Sum(key)
+	c.sseHeaders = map[string]string{
+		sseAlgorithmHeader: "AES256",
+		sseKeyHeader:       base64.StdEncoding.EncodeToString(key),
+		sseKeyMD5Header:    base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
@@ -0,0 +1,45 @@
+package uploadbig
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"testing"
+)
+
+func TestSSEHeadersOnEveryPhase(t *testing.T) {
+	server := newTestServer(t, nil)
+	key := bytes.Repeat([]byte{7}, 32)
+	u := New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetSSECustomerKey(key)
+	u.SetFlush(2, FlushRequest{Method: "POST", URL: server.URL + "/flush"})
+	u.SetFinalize(FlushRequest{Method: "POST", URL: server.URL + "/complete"}, S3ManifestEncoder)
+	recorder := &memoryRecorder{}
+	u.RecordTo(recorder)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	sum := md5.Sum(key)
+	want := map[string]string{
+		"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+		"X-Amz-Server-Side-Encryption-Customer-Key":       base64.StdEncoding.EncodeToString(key),
+		"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   base64.StdEncoding.EncodeToString(sum[:]),
+	}
+	requests := server.Requests()
+	if len(requests) != 6 {
+		t.Fatalf("%d requests, want 3 chunks, 2 flushes and finalize", len(requests))
+	}
+	for _, request := range requests {
+		for name, value := range want {
+			if got := request.Header.Get(name); got != value {
+				t.Errorf("%s %s: %s is %q", request.Method, request.URL, name, got)
+			}
+		}
+	}
+	for _, request := range recorder.requests {
+		if got := request.Header.Get("X-Amz-Server-Side-Encryption-Customer-Key"); got != "REDACTED" {
+			t.Errorf("recorded key %q", got)
+		}
+	}
+}
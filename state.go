@@ -0,0 +1,87 @@
+package uploadbig
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ResumeState is the upload state persisted between runs
+type ResumeState struct {
+	SessionID string    `json:"sessionId"`
+	FilePath  string    `json:"filePath"`
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	Offset    int64     `json:"offset"`
+}
+
+// SaveState writes the state to path atomically
+func SaveState(path string, state ResumeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadState reads the state saved by SaveState
+func LoadState(path string) (ResumeState, error) {
+	var state ResumeState
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
+
+// SetStatePath sets the file the upload state is persisted to. Init and
+// Resume save the session ID with the size and modification time of the
+// file, and Resume continues the saved session. Append also keeps the
+// offset sent so far.
+func (c *UploadData) SetStatePath(path string) {
+	c.statePath = path
+}
+
+// restoreSession makes Resume continue the session of the saved state, if any
+func (c *UploadData) restoreSession() error {
+	state, err := LoadState(c.statePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.setSessionID(state.SessionID)
+	return nil
+}
+
+// saveRunState saves what CanResume checks. The offset is not tracked,
+// Resume asks the server for it.
+func (c *UploadData) saveRunState() error {
+	fileStat, err := os.Stat(c.filePath)
+	if err != nil {
+		return err
+	}
+	return SaveState(c.statePath, ResumeState{
+		SessionID: c.id,
+		FilePath:  c.filePath,
+		Size:      fileStat.Size(),
+		ModTime:   fileStat.ModTime(),
+	})
+}
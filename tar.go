@@ -0,0 +1,149 @@
+package uploadbig
+
+import (
+	"archive/tar"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// NewTarUploader creates new instance uploading a tar archive of paths
+// produced on the fly, without a temporary tar file. Directories are added
+// with their whole tree under their base name, files under their base name.
+// The archive size is computed up front from the headers as the upload
+// needs it; a file changing size before it is sent fails the upload.
+func NewTarUploader(method string, url string, paths []string, client *http.Client,
+	chunkSize int, logger *Logger) (*UploadData, error) {
+
+	entries, err := tarEntries(paths)
+	if err != nil {
+		return nil, err
+	}
+	size, err := tarSize(entries)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &tarStream{entries: entries}
+	uploadData := NewUploaderFromReader(method, url, stream, size, client, chunkSize, logger)
+	uploadData.readerCloser = stream
+	return uploadData, nil
+}
+
+type tarEntry struct {
+	path   string
+	header *tar.Header
+}
+
+func tarEntries(paths []string) ([]tarEntry, error) {
+	var entries []tarEntry
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		base := filepath.Dir(root)
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(base, path)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(name)
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			entries = append(entries, tarEntry{path: path, header: header})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// tarSize returns the size of the archive of entries: the encoded headers,
+// the contents padded to the block size and the two end blocks
+func tarSize(entries []tarEntry) (int64, error) {
+	const blockSize = 512
+
+	var size int64
+	for _, entry := range entries {
+		counter := &countingWriter{}
+		err := tar.NewWriter(counter).WriteHeader(entry.header)
+		if err != nil {
+			return 0, err
+		}
+		size += counter.size + (entry.header.Size+blockSize-1)/blockSize*blockSize
+	}
+	return size + 2*blockSize, nil
+}
+
+type countingWriter struct {
+	size int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	return len(p), nil
+}
+
+// tarStream writes the archive into a pipe on the first Read
+type tarStream struct {
+	entries []tarEntry
+	once    sync.Once
+	reader  *io.PipeReader
+}
+
+func (s *tarStream) Read(p []byte) (int, error) {
+	s.once.Do(s.start)
+	return s.reader.Read(p)
+}
+
+// Close stops the writing of the archive
+func (s *tarStream) Close() error {
+	s.once.Do(s.start)
+	return s.reader.Close()
+}
+
+func (s *tarStream) start() {
+	reader, writer := io.Pipe()
+	s.reader = reader
+	go func() {
+		writer.CloseWithError(writeTar(writer, s.entries))
+	}()
+}
+
+func writeTar(writer io.Writer, entries []tarEntry) error {
+	tarWriter := tar.NewWriter(writer)
+	for _, entry := range entries {
+		err := tarWriter.WriteHeader(entry.header)
+		if err != nil {
+			return err
+		}
+		if entry.header.Typeflag != tar.TypeReg {
+			continue
+		}
+		err = copyTarFile(tarWriter, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return tarWriter.Close()
+}
+
+func copyTarFile(writer io.Writer, entry tarEntry) error {
+	file, err := os.Open(entry.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.CopyN(writer, file, entry.header.Size)
+	return err
+}
@@ -0,0 +1,58 @@
+package uploadbig
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTarUploader(t *testing.T) {
+	server := newTestServer(t, nil)
+	dir := t.TempDir()
+	first := []byte("hello")
+	second := testContent(3000)
+	if err := os.Mkdir(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "docs", "a.txt"), first, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "b.bin"), second, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{filepath.Join(dir, "docs"), filepath.Join(dir, "b.bin")}
+	u, err := NewTarUploader("PUT", server.URL, paths, server.Client(), 1000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := map[string][]byte{}
+	archive := tar.NewReader(bytes.NewReader(server.Received(int(u.Status.Size))))
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			data, err := ioutil.ReadAll(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			extracted[header.Name] = data
+		}
+	}
+	if len(extracted) != 2 || !bytes.Equal(extracted["docs/a.txt"], first) || !bytes.Equal(extracted["b.bin"], second) {
+		t.Fatalf("extracted %d files: %v", len(extracted), extracted)
+	}
+}
@@ -0,0 +1,108 @@
+package uploadbig
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BandwidthLimiter caps the bandwidth of the uploads sharing it: every
+// chunk attempt waits until the bytes sent before it keep the average under
+// the limit
+type BandwidthLimiter struct {
+	bytesPerSecond int64
+	mutex          sync.Mutex
+	next           time.Time
+}
+
+// NewBandwidthLimiter creates a limiter of bytesPerSecond, which must be
+// positive
+func NewBandwidthLimiter(bytesPerSecond int64) *BandwidthLimiter {
+	return &BandwidthLimiter{bytesPerSecond: bytesPerSecond}
+}
+
+// wait waits until n bytes can be sent. It returns false when ctx is done first.
+func (l *BandwidthLimiter) wait(ctx context.Context, n int) bool {
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSecond))
+	l.mutex.Unlock()
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// SetMaxBytesPerSecond caps the upload bandwidth: every chunk attempt waits
+// until the bytes sent before it, by any of the concurrent chunks, keep
+// the average under limit. Zero removes the cap.
+func (c *UploadData) SetMaxBytesPerSecond(limit int64) {
+	c.bandwidth = nil
+	if limit > 0 {
+		c.bandwidth = NewBandwidthLimiter(limit)
+	}
+}
+
+// SetBandwidthLimiter makes the upload share the bandwidth cap of limiter
+// with the other uploads using it. nil removes the cap.
+func (c *UploadData) SetBandwidthLimiter(limiter *BandwidthLimiter) {
+	c.bandwidth = limiter
+}
+
+// throttle waits until n bytes can be sent. It returns false when the
+// upload is cancelled first.
+func (c *UploadData) throttle(n int) bool {
+	return c.bandwidth == nil || c.bandwidth.wait(c.ctx, n)
+}
+
+// Limiter caps the chunk requests in flight at once across the uploads
+// sharing it
+type Limiter struct {
+	slots chan struct{}
+}
+
+// NewLimiter creates a limiter of n requests, at least one
+func NewLimiter(n int) *Limiter {
+	if n < 1 {
+		n = 1
+	}
+	return &Limiter{slots: make(chan struct{}, n)}
+}
+
+// SetLimiter makes every chunk attempt wait for a free request of limiter,
+// shared with the other uploads using it. nil removes the cap.
+func (c *UploadData) SetLimiter(limiter *Limiter) {
+	c.limiter = limiter
+}
+
+// acquireSlot waits for a free request of the limiter. It returns false
+// when the upload is cancelled first.
+func (c *UploadData) acquireSlot() bool {
+	if c.limiter == nil {
+		return true
+	}
+	select {
+	case c.limiter.slots <- struct{}{}:
+		return true
+	case <-c.ctx.Done():
+		return false
+	}
+}
+
+func (c *UploadData) releaseSlot() {
+	if c.limiter != nil {
+		<-c.limiter.slots
+	}
+}
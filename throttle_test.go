@@ -0,0 +1,38 @@
+package uploadbig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaxBytesPerSecond(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetMaxBytesPerSecond(5000)
+	// the limit applies across concurrent chunks
+	u.SetConcurrency(4)
+
+	start := time.Now()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// the first chunk goes at once, the other 4000 bytes take 800ms
+	if elapsed := time.Since(start); elapsed < 700*time.Millisecond || elapsed > 1500*time.Millisecond {
+		t.Fatalf("uploaded in %v, want about 800ms", elapsed)
+	}
+}
+
+func TestMaxBytesPerSecondDisabled(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetMaxBytesPerSecond(5000)
+	u.SetMaxBytesPerSecond(0)
+
+	start := time.Now()
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("uploaded in %v without a limit", elapsed)
+	}
+}
@@ -0,0 +1,69 @@
+package uploadbig
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SetResponseHeaderTimeout limits the wait for the response headers after
+// a chunk has been written. See configureTransport.
+func (c *UploadData) SetResponseHeaderTimeout(timeout time.Duration) error {
+	return c.configureTransport(func(transport *http.Transport) {
+		transport.ResponseHeaderTimeout = timeout
+	})
+}
+
+// SetProxy sends the requests through the proxy at proxyURL, which may
+// carry credentials. See configureTransport.
+func (c *UploadData) SetProxy(proxyURL string) error {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+	return c.configureTransport(func(transport *http.Transport) {
+		transport.Proxy = http.ProxyURL(parsed)
+	})
+}
+
+// configureTransport changes a clone of the client's transport and makes the
+// uploader use a copy of the client with it, so the client passed to New
+// and its other users are not modified. A transport other than an
+// *http.Transport can't be configured.
+func (c *UploadData) configureTransport(configure func(transport *http.Transport)) error {
+	client := c.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var transport *http.Transport
+	switch roundTripper := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = roundTripper.Clone()
+	default:
+		return fmt.Errorf("transport %T can't be configured", roundTripper)
+	}
+	configure(transport)
+
+	clientCopy := *client
+	clientCopy.Transport = transport
+	c.client = &clientCopy
+	return nil
+}
+
+// SetExpectContinue sends every chunk with "Expect: 100-continue", so a
+// server can refuse it before the body is sent. Without a 100 Continue
+// within timeout the body is sent anyway. See configureTransport.
+func (c *UploadData) SetExpectContinue(timeout time.Duration) error {
+	err := c.configureTransport(func(transport *http.Transport) {
+		transport.ExpectContinueTimeout = timeout
+	})
+	if err != nil {
+		return err
+	}
+	c.expectContinue = true
+	return nil
+}
@@ -0,0 +1,119 @@
+package uploadbig
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		if n == 0 {
+			time.Sleep(300 * time.Millisecond)
+		}
+	})
+	client := server.Client()
+
+	u := New("PUT", server.URL, testFile(t, 1000), client, 1000, nil)
+	if err := u.SetResponseHeaderTimeout(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(server.Requests()); got != 2 {
+		t.Fatalf("%d requests, want the delayed one retried", got)
+	}
+	if client.Transport.(*http.Transport).ResponseHeaderTimeout != 0 {
+		t.Fatal("the transport of the client passed to New was modified")
+	}
+}
+
+func TestProxy(t *testing.T) {
+	proxy := newTestServer(t, nil)
+	u := New("PUT", "http://upload.example/files", testFile(t, 10), http.DefaultClient, 1000, nil)
+	if err := u.SetProxy("http://user:pass@" + proxy.Listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := proxy.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("%d requests through the proxy, want 1", len(requests))
+	}
+	// a plain HTTP request is forwarded with its absolute URL
+	if got := requests[0].URL; got != "http://upload.example/files" {
+		t.Errorf("forwarded URL %s", got)
+	}
+	if got := requests[0].Header.Get("Proxy-Authorization"); got != "Basic dXNlcjpwYXNz" {
+		t.Errorf("proxy authorization %q", got)
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Error("the default client was modified")
+	}
+}
+
+// silentContinueServer never answers "Expect: 100-continue" and sends on
+// delays how long every body took to arrive after its headers
+func silentContinueServer(t *testing.T, delays chan<- time.Duration) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					request, err := http.ReadRequest(reader)
+					if err != nil {
+						return
+					}
+					length, _ := strconv.Atoi(request.Header.Get("Content-Length"))
+					start := time.Now()
+					io.ReadFull(reader, make([]byte, length))
+					if request.Header.Get("Expect") == "100-continue" {
+						delays <- time.Since(start)
+					}
+					io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+				}
+			}()
+		}
+	}()
+	return "http://" + listener.Addr().String()
+}
+
+func TestExpectContinueTimeout(t *testing.T) {
+	for _, timeout := range []time.Duration{50 * time.Millisecond, 400 * time.Millisecond} {
+		delays := make(chan time.Duration, 1)
+		url := silentContinueServer(t, delays)
+		u := New("PUT", url, testFile(t, 1000), &http.Client{}, 1000, nil)
+		if err := u.SetExpectContinue(timeout); err != nil {
+			t.Fatal(err)
+		}
+		if err := u.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case delay := <-delays:
+			if delay < timeout*8/10 || delay > timeout+200*time.Millisecond {
+				t.Errorf("timeout %v: body sent after %v", timeout, delay)
+			}
+		default:
+			t.Fatalf("timeout %v: the chunk was sent without Expect", timeout)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SetTreeHash makes the upload compute the SHA-256 tree hash of the file
+// (the scheme of AWS Glacier, with 1 MB leaves). Leaves are hashed
+// concurrently as the chunks are read. Every chunk must start at a multiple
+// of MB.
+func (c *UploadData) SetTreeHash(enabled bool) {
+	c.treeHashEnabled = enabled
+}
+
+// TreeHash returns the hex encoded tree hash computed by a successful upload
+func (c *UploadData) TreeHash() string {
+	return c.treeHash
+}
+
+func (c *UploadData) startTreeHash() error {
+	c.treeHash = ""
+	c.treeLeaves = nil
+	if !c.treeHashEnabled {
+		return nil
+	}
+
+	for _, chunk := range c.chunks {
+		if chunk.Offset%MB != 0 {
+			return fmt.Errorf("tree hash needs chunks aligned to 1 MB, part %d starts at %d", chunk.Index, chunk.Offset)
+		}
+	}
+	end := int64(0)
+	if len(c.chunks) > 0 {
+		last := c.chunks[len(c.chunks)-1]
+		end = last.Offset + int64(last.Length)
+	}
+	c.treeLeaves = make([][sha256.Size]byte, (end+MB-1)/MB)
+	return nil
+}
+
+func (c *UploadData) hashTreeLeaves(offset int64, part []byte) {
+	if c.treeLeaves == nil {
+		return
+	}
+
+	leaves := c.treeLeaves
+	c.treeWait.Add(1)
+	go func() {
+		defer c.treeWait.Done()
+		for from := 0; from < len(part); from += MB {
+			to := from + MB
+			if to > len(part) {
+				to = len(part)
+			}
+			leaves[(offset+int64(from))/MB] = sha256.Sum256(part[from:to])
+		}
+	}()
+}
+
+func (c *UploadData) finishTreeHash() {
+	if c.treeLeaves == nil {
+		return
+	}
+	c.treeWait.Wait()
+	root := treeHashRoot(c.treeLeaves)
+	c.treeHash = hex.EncodeToString(root[:])
+}
+
+func treeHashRoot(leaves [][sha256.Size]byte) [sha256.Size]byte {
+	if len(leaves) == 0 {
+		return sha256.Sum256(nil)
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		next := make([][sha256.Size]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, sha256.Sum256(append(level[i][:], level[i+1][:]...)))
+		}
+		level = next
+	}
+	return level[0]
+}
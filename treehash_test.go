@@ -0,0 +1,43 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func sha256Of(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+func TestTreeHash(t *testing.T) {
+	server := newTestServer(t, nil)
+	content := testContent(2*MB + 100)
+	l0, l1, l2 := sha256Of(content[:MB]), sha256Of(content[MB:2*MB]), sha256Of(content[2*MB:])
+	// an odd leaf is promoted to the next level unchanged
+	want := hex.EncodeToString(sha256Of(sha256Of(l0, l1), l2))
+
+	for _, chunkSize := range []int{MB, 2 * MB} {
+		u := New("PUT", server.URL, testFile(t, len(content)), server.Client(), chunkSize, nil)
+		u.SetTreeHash(true)
+		if err := u.Init(); err != nil {
+			t.Fatal(err)
+		}
+		if got := u.TreeHash(); got != want {
+			t.Errorf("chunk size %d: tree hash %s, want %s", chunkSize, got, want)
+		}
+	}
+}
+
+func TestTreeHashNeedsAlignedChunks(t *testing.T) {
+	server := newTestServer(t, nil)
+	u := New("PUT", server.URL, testFile(t, 2*MB), server.Client(), MB+1, nil)
+	u.SetTreeHash(true)
+	if err := u.Init(); err == nil {
+		t.Fatal("unaligned chunks accepted")
+	}
+}
@@ -0,0 +1,99 @@
+package uploadbig
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// TusVersion is the tus protocol version sent in Tus-Resumable
+const TusVersion = "1.0.0"
+
+// SetTus makes the upload speak the tus resumable upload protocol: Init
+// creates the upload with a POST to the upload URL carrying Upload-Length,
+// then PATCHes the chunks to the URL of its Location header with
+// Upload-Offset, and follows the Upload-Offset of the responses. Resume
+// continues the upload at TusUploadURL, or the one set by
+// SetTusUploadURL, from the offset of a HEAD. The chunks are sent one at
+// a time.
+func (c *UploadData) SetTus(enabled bool) {
+	c.tus = enabled
+}
+
+// TusUploadURL returns the URL of the tus upload created by Init, to be
+// persisted for resuming it
+func (c *UploadData) TusUploadURL() string {
+	return c.tusUploadURL
+}
+
+// SetTusUploadURL sets the tus upload Resume continues
+func (c *UploadData) SetTusUploadURL(uploadURL string) {
+	c.tusUploadURL = uploadURL
+}
+
+// chunkURL returns the URL the chunks are sent to
+func (c *UploadData) chunkURL() string {
+	if c.tus {
+		return c.tusUploadURL
+	}
+	return c.url
+}
+
+// chunkMethod returns the method the chunks are sent with
+func (c *UploadData) chunkMethod() string {
+	if c.tus {
+		return http.MethodPatch
+	}
+	return c.method
+}
+
+// tusHeaders adds the tus headers of the chunk at offset
+func tusHeaders(headers map[string]string, offset int64) {
+	headers["Upload-Offset"] = strconv.FormatInt(offset, 10)
+	headers["Content-Type"] = "application/offset+octet-stream"
+}
+
+// createTus creates the tus upload and keeps its URL
+func (c *UploadData) createTus(fileName string) error {
+	request, err := http.NewRequestWithContext(c.ctx, http.MethodPost, c.url, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Session-ID", c.id)
+	setHeaders(request, c.sharedHeaders(nil))
+	request.Header.Set("Upload-Length", strconv.FormatInt(c.Status.Size, 10))
+	if fileName != "" {
+		request.Header.Set("Upload-Metadata", "filename "+base64.StdEncoding.EncodeToString([]byte(fileName)))
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode != http.StatusCreated {
+		return fmt.Errorf("tus creation failed with HTTP code %d", response.StatusCode)
+	}
+	location := response.Header.Get("Location")
+	if location == "" {
+		return errors.New("tus creation response has no Location")
+	}
+	base, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	uploadURL, err := base.Parse(location)
+	if err != nil {
+		return err
+	}
+	c.tusUploadURL = uploadURL.String()
+	c.infof("Upload %s: tus upload %s created", c.id, c.tusUploadURL)
+	return nil
+}
@@ -0,0 +1,109 @@
+package uploadbig
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// tusServer is a tus server storing at most 700 bytes of every PATCH
+type tusServer struct {
+	*testServer
+	mutex  sync.Mutex
+	stored []byte
+}
+
+func newTusServer(t *testing.T) *tusServer {
+	s := &tusServer{}
+	s.testServer = newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if r.Header.Get("Tus-Resumable") != TusVersion {
+			t.Errorf("%s without Tus-Resumable", r.Method)
+		}
+		switch r.Method {
+		case http.MethodPost:
+			if r.Header.Get("Upload-Length") != "5000" {
+				t.Errorf("Upload-Length %q", r.Header.Get("Upload-Length"))
+			}
+			w.Header().Set("Location", "/files/abc")
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodHead:
+			w.Header().Set("Upload-Offset", strconv.Itoa(len(s.stored)))
+		case http.MethodPatch:
+			if r.URL.Path != "/files/abc" || r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+				t.Errorf("PATCH %s with Content-Type %q", r.URL, r.Header.Get("Content-Type"))
+			}
+			if offset, _ := strconv.Atoi(r.Header.Get("Upload-Offset")); offset != len(s.stored) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			if len(body) > 700 {
+				body = body[:700]
+			}
+			s.stored = append(s.stored, body...)
+			w.Header().Set("Upload-Offset", strconv.Itoa(len(s.stored)))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	return s
+}
+
+func TestTus(t *testing.T) {
+	server := newTusServer(t)
+	u := New("PUT", server.URL+"/files", testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetTus(true)
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the chunks follow the offset of the responses
+	if !bytes.Equal(server.stored, testContent(5000)) {
+		t.Fatalf("stored %d bytes differing from the content", len(server.stored))
+	}
+	if u.TusUploadURL() != server.URL+"/files/abc" {
+		t.Fatalf("upload URL %s", u.TusUploadURL())
+	}
+	if u.Status.SizeTransferred != 5000 {
+		t.Fatalf("transferred %d bytes", u.Status.SizeTransferred)
+	}
+}
+
+func TestTusResume(t *testing.T) {
+	server := newTusServer(t)
+	server.stored = append([]byte(nil), testContent(5000)[:2100]...)
+	u := New("PUT", server.URL+"/files", testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetTus(true)
+	u.SetTusUploadURL(server.URL + "/files/abc")
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(server.stored, testContent(5000)) {
+		t.Fatalf("stored %d bytes differing from the content", len(server.stored))
+	}
+	if requests := server.Requests(); requests[0].Method != http.MethodHead {
+		t.Fatalf("resumed with a %s", requests[0].Method)
+	}
+}
+
+func TestTusKeepsItsContentType(t *testing.T) {
+	server := newTusServer(t)
+	u := New("PUT", server.URL+"/files", testFile(t, 5000), server.Client(), 1000, nil)
+	u.SetTus(true)
+	u.SetContentType("image/jpeg")
+	u.SetContentTypeFunc(func(index uint64) string {
+		return "application/json"
+	})
+	// the server fails the test on any other Content-Type
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(server.stored, testContent(5000)) {
+		t.Fatalf("stored %d bytes differing from the content", len(server.stored))
+	}
+}
@@ -19,22 +19,18 @@ func generateSessionID() string {
 	return fmt.Sprintf("%X", b)
 }
 
-func generateContentRange(index uint64, fileChunk int, partSize int, totalSize int64) string {
-	var contentRange string
-	if index == 0 {
-		to := uint64(partSize) - 1
-		if to >= uint64(totalSize) {
-			to = uint64(totalSize) - 1
-		}
-		contentRange = "bytes 0-" + fmt.Sprintf("%v", to) + "/" + fmt.Sprintf("%v", totalSize)
-	} else {
-		from := uint64(fileChunk) * index
-		to := (uint64(fileChunk) * (index + 1)) - 1
-		if to >= uint64(totalSize) {
-			to = uint64(totalSize) - 1
-		}
-		contentRange = "bytes " + fmt.Sprintf("%v", from) + "-" + fmt.Sprintf("%v", to) + "/" + fmt.Sprintf("%v", totalSize)
+// generateContentRange returns the inclusive range of the partSize bytes sent
+// from offset. It depends on the offset only, so a part keeps its absolute
+// range whatever its index in a resumed or realigned plan.
+func generateContentRange(offset int64, partSize int, totalSize int64) string {
+	from := offset
+	to := from + int64(partSize) - 1
+	if to >= totalSize {
+		to = totalSize - 1
 	}
+	return formatContentRange(from, to, totalSize)
+}
 
-	return contentRange
+func formatContentRange(from int64, to int64, totalSize int64) string {
+	return "bytes " + fmt.Sprintf("%v", from) + "-" + fmt.Sprintf("%v", to) + "/" + fmt.Sprintf("%v", totalSize)
 }
@@ -0,0 +1,22 @@
+package uploadbig
+
+import "testing"
+
+func TestGenerateContentRange(t *testing.T) {
+	const total = 2*MB + MB/2
+	for _, test := range []struct {
+		name     string
+		offset   int64
+		partSize int
+		want     string
+	}{
+		{"first", 0, MB, "bytes 0-1048575/2621440"},
+		{"middle", MB, MB, "bytes 1048576-2097151/2621440"},
+		{"final partial", 2 * MB, MB / 2, "bytes 2097152-2621439/2621440"},
+		{"past the end", 2 * MB, MB, "bytes 2097152-2621439/2621440"},
+	} {
+		if got := generateContentRange(test.offset, test.partSize, total); got != test.want {
+			t.Errorf("%s chunk: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package uploadbig
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrVerifyMismatch is returned when the object downloaded after the upload
+// doesn't hash to the uploaded content
+var ErrVerifyMismatch = errors.New("downloaded object differs from the uploaded content")
+
+// SetVerifyByDownload makes the upload, once it has been finalized, GET
+// downloadURL with the shared headers and compare the SHA-256 of the body
+// to the SHA-256 of the content sent, hashed as the chunks are read, failing
+// with ErrVerifyMismatch when they differ. Empty disables it.
+func (c *UploadData) SetVerifyByDownload(downloadURL string) {
+	c.verifyURL = downloadURL
+}
+
+func (c *UploadData) startVerify() {
+	c.verifyDigest, c.verifyHashed = nil, 0
+	if c.verifyURL != "" {
+		c.verifyDigest = sha256.New()
+	}
+}
+
+// hashVerified adds the bytes of part, read at offset, to the verify hash.
+// Only the bytes following those hashed so far are added, a chunk read
+// again after a realignment isn't hashed twice.
+func (c *UploadData) hashVerified(offset int64, part []byte) {
+	if c.verifyDigest == nil {
+		return
+	}
+	next := c.verifyHashed
+	if offset > next || offset+int64(len(part)) <= next {
+		return
+	}
+	c.verifyDigest.Write(part[next-offset:])
+	c.verifyHashed += offset + int64(len(part)) - next
+}
+
+// sourceDigest returns the SHA-256 of the uploaded content
+func (c *UploadData) sourceDigest() (string, error) {
+	// an empty plan hashes no bytes, the digest is the one of no content
+	if len(c.chunks) == 0 {
+		return hex.EncodeToString(c.verifyDigest.Sum(nil)), nil
+	}
+	last := c.chunks[len(c.chunks)-1]
+	end := last.Offset + int64(last.Length)
+	if c.verifyHashed != end {
+		return "", fmt.Errorf("can't verify, %d of %d bytes have been hashed", c.verifyHashed, end)
+	}
+	return hex.EncodeToString(c.verifyDigest.Sum(nil)), nil
+}
+
+func (c *UploadData) verifyByDownload() error {
+	if c.verifyURL == "" {
+		return nil
+	}
+	expected, err := c.sourceDigest()
+	if err != nil {
+		return err
+	}
+
+	downloaded, err := c.downloadDigest()
+	if err != nil {
+		return err
+	}
+	if downloaded != expected {
+		return fmt.Errorf("%w: sha-256 %s, uploaded %s", ErrVerifyMismatch, downloaded, expected)
+	}
+	c.infof("Upload %s: verified by download", c.id)
+	return nil
+}
+
+func (c *UploadData) downloadDigest() (string, error) {
+	request, err := http.NewRequestWithContext(c.ctx, http.MethodGet, c.verifyURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Session-ID", c.id)
+	setHeaders(request, c.sharedHeaders(nil))
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", fmt.Errorf("verify download failed with HTTP code %d", response.StatusCode)
+	}
+
+	digest := sha256.New()
+	_, err = io.Copy(digest, response.Body)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
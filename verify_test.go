@@ -0,0 +1,98 @@
+package uploadbig
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// storeServer reassembles the chunks it receives and serves them on GET,
+// with the byte at corrupt flipped when corrupt isn't negative
+func storeServer(t *testing.T, size int, corrupt int) *testServer {
+	var mutex sync.Mutex
+	store := make([]byte, size)
+	return newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if r.Method == http.MethodGet {
+			data := append([]byte(nil), store...)
+			if corrupt >= 0 {
+				data[corrupt] ^= 0xff
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data)
+			return
+		}
+		var from, to, total int
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &from, &to, &total)
+		body, _ := ioutil.ReadAll(r.Body)
+		copy(store[from:], body)
+	})
+}
+
+func TestVerifyByDownload(t *testing.T) {
+	server := storeServer(t, 2500, -1)
+	u := New("PUT", server.URL+"/upload", testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetVerifyByDownload(server.URL + "/object")
+	reads := 0
+	u.SetReadFunc(func(read ReadFunc) ReadFunc {
+		return func(dst []byte, offset int64) (int, error) {
+			reads++
+			return read(dst, offset)
+		}
+	})
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the content is hashed as it is uploaded, not read again
+	if reads != 3 {
+		t.Fatalf("%d reads, want one per chunk", reads)
+	}
+	if requests := server.Requests(); requests[len(requests)-1].Method != http.MethodGet {
+		t.Fatal("the object was not downloaded")
+	}
+}
+
+func TestVerifyByDownloadMismatch(t *testing.T) {
+	server := storeServer(t, 2500, 1234)
+	u := New("PUT", server.URL+"/upload", testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetVerifyByDownload(server.URL + "/object")
+	if err := u.Init(); !errors.Is(err, ErrVerifyMismatch) {
+		t.Fatalf("got error %v, want %v", err, ErrVerifyMismatch)
+	}
+}
+
+func TestVerifyByDownloadResumed(t *testing.T) {
+	// the server has the first chunk from an earlier attempt
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request, n int) {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Upload-Offset", "1000")
+		case http.MethodGet:
+			w.Write(testContent(2500))
+		}
+	})
+	u := New("PUT", server.URL+"/upload", testFile(t, 2500), server.Client(), 1000, nil)
+	u.SetVerifyByDownload(server.URL + "/object")
+	if err := u.Resume(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyEmptyFile(t *testing.T) {
+	server := storeServer(t, 0, -1)
+	u := New("PUT", server.URL+"/upload", testFile(t, 0), server.Client(), 1000, nil)
+	u.SetVerifyByDownload(server.URL + "/object")
+	if err := u.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// only the download of the empty content
+	if got := server.Requests(); len(got) != 1 || got[0].Method != http.MethodGet {
+		t.Fatalf("requests %v", got)
+	}
+}
@@ -0,0 +1,43 @@
+package uploadbig
+
+import (
+	"fmt"
+	"io"
+)
+
+// SetReaderWrapper makes Init read the source through wrap(source), e.g. to
+// tee it into a local copy or count the bytes. The wrapper sees the content
+// in order, before SetReadFunc, compression and multipart encoding. A file
+// source is then read as a stream: the upload can't realign to a server
+// offset and SetConcurrency and SetPrefetchLastChunk have no effect.
+func (c *UploadData) SetReaderWrapper(wrap func(source io.Reader) io.Reader) {
+	c.readerWrapper = wrap
+}
+
+func (c *UploadData) wrapSource() {
+	c.wrapped = nil
+	if c.readerWrapper == nil || len(c.chunks) == 0 {
+		return
+	}
+
+	first := c.chunks[0].Offset
+	last := c.chunks[len(c.chunks)-1]
+	source := c.reader
+	if !c.fromReader {
+		source = io.NewSectionReader(c.file, first, last.Offset+int64(last.Length)-first)
+	}
+	c.wrapped = c.readerWrapper(source)
+	c.wrappedOffset = first
+}
+
+func (c *UploadData) readWrapped(part []byte, offset int64) (int, error) {
+	if offset != c.wrappedOffset {
+		return 0, fmt.Errorf("wrapped source is at offset %d, can't read at %d", c.wrappedOffset, offset)
+	}
+	readBytes, err := io.ReadFull(checkedReader{c.wrapped}, part)
+	c.wrappedOffset += int64(readBytes)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("wrapped source ended at %d bytes", offset+int64(readBytes))
+	}
+	return readBytes, err
+}
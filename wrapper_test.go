@@ -0,0 +1,34 @@
+package uploadbig
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestReaderWrapperTee(t *testing.T) {
+	content := testContent(2500)
+	for _, fromReader := range []bool{false, true} {
+		server := newTestServer(t, nil)
+		var u *UploadData
+		if fromReader {
+			u = NewUploaderFromReader("PUT", server.URL, bytes.NewReader(content), 2500, server.Client(), 1000, nil)
+		} else {
+			u = New("PUT", server.URL, testFile(t, 2500), server.Client(), 1000, nil)
+		}
+		var local bytes.Buffer
+		u.SetReaderWrapper(func(source io.Reader) io.Reader {
+			return io.TeeReader(source, &local)
+		})
+		if err := u.Init(); err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(local.Bytes(), content) {
+			t.Errorf("reader %v: the local copy has %d bytes differing from the content", fromReader, local.Len())
+		}
+		if !bytes.Equal(server.Received(2500), local.Bytes()) {
+			t.Errorf("reader %v: the uploaded bytes differ from the local copy", fromReader)
+		}
+	}
+}